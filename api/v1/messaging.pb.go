@@ -27,14 +27,19 @@ type Location_Kind int32
 
 const (
 	Location_MINIO Location_Kind = 0
+	// Directory tree on the local file system,
+	// the bucket is a directory and the object name is a file path.
+	Location_FILESYSTEM Location_Kind = 1
 )
 
 var Location_Kind_name = map[int32]string{
 	0: "MINIO",
+	1: "FILESYSTEM",
 }
 
 var Location_Kind_value = map[string]int32{
-	"MINIO": 0,
+	"MINIO":      0,
+	"FILESYSTEM": 1,
 }
 
 func (x Location_Kind) String() string {
@@ -122,10 +127,22 @@ type InputFrame struct {
 	FrameId string `protobuf:"bytes,1,opt,name=frame_id,json=frameId,proto3" json:"frame_id,omitempty"`
 	// The location of the data frame
 	// on a distributed file system.
-	FrameLocation        *Location `protobuf:"bytes,2,opt,name=frame_location,json=frameLocation,proto3" json:"frame_location,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+	FrameLocation *Location `protobuf:"bytes,2,opt,name=frame_location,json=frameLocation,proto3" json:"frame_location,omitempty"`
+	// Hex-encoded SHA-256 checksum of the data frame.
+	// The data frame is verified before the conversion, if set.
+	Checksum string `protobuf:"bytes,3,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// Size of the data frame in bytes.
+	// The data frame is verified before the conversion, if set.
+	SizeBytes int64 `protobuf:"varint,4,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	// Custom attributes of the data frame, e.g. tenant id or correlation id,
+	// that are passed through to the converted blob.
+	Attributes map[string]string `protobuf:"bytes,5,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Time of the data frame in Unix milliseconds, e.g. the capture time.
+	// The pipeline sets it to the time of the input message, if it is not set.
+	TimestampMs          int64    `protobuf:"varint,6,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *InputFrame) Reset()         { *m = InputFrame{} }
@@ -175,6 +192,34 @@ func (m *InputFrame) GetFrameLocation() *Location {
 	return nil
 }
 
+func (m *InputFrame) GetChecksum() string {
+	if m != nil {
+		return m.Checksum
+	}
+	return ""
+}
+
+func (m *InputFrame) GetSizeBytes() int64 {
+	if m != nil {
+		return m.SizeBytes
+	}
+	return 0
+}
+
+func (m *InputFrame) GetAttributes() map[string]string {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
+func (m *InputFrame) GetTimestampMs() int64 {
+	if m != nil {
+		return m.TimestampMs
+	}
+	return 0
+}
+
 type ConvertedBlob struct {
 	//
 	FrameId string `protobuf:"bytes,1,opt,name=frame_id,json=frameId,proto3" json:"frame_id,omitempty"`
@@ -182,10 +227,24 @@ type ConvertedBlob struct {
 	// on a distributed file system.
 	FrameLocation *Location `protobuf:"bytes,2,opt,name=frame_location,json=frameLocation,proto3" json:"frame_location,omitempty"`
 	//
-	ConvertedLocation    *Location `protobuf:"bytes,3,opt,name=converted_location,json=convertedLocation,proto3" json:"converted_location,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+	ConvertedLocation *Location `protobuf:"bytes,3,opt,name=converted_location,json=convertedLocation,proto3" json:"converted_location,omitempty"`
+	// Hex-encoded SHA-256 checksum of the converted blob.
+	Checksum string `protobuf:"bytes,4,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// Size of the converted blob in bytes.
+	SizeBytes int64 `protobuf:"varint,5,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	// MIME type of the converted blob, e.g. "application/json".
+	ContentType string `protobuf:"bytes,6,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Encoding of the converted blob content, e.g. "gzip",
+	// empty if the content is not encoded.
+	ContentEncoding string `protobuf:"bytes,7,opt,name=content_encoding,json=contentEncoding,proto3" json:"content_encoding,omitempty"`
+	// Custom attributes of the data frame,
+	// and the attributes that are added by the converter.
+	Attributes map[string]string `protobuf:"bytes,8,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Size of the data frame, that the blob has been converted from, in bytes.
+	FrameSizeBytes       int64    `protobuf:"varint,9,opt,name=frame_size_bytes,json=frameSizeBytes,proto3" json:"frame_size_bytes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ConvertedBlob) Reset()         { *m = ConvertedBlob{} }
@@ -242,35 +301,93 @@ func (m *ConvertedBlob) GetConvertedLocation() *Location {
 	return nil
 }
 
+func (m *ConvertedBlob) GetChecksum() string {
+	if m != nil {
+		return m.Checksum
+	}
+	return ""
+}
+
+func (m *ConvertedBlob) GetSizeBytes() int64 {
+	if m != nil {
+		return m.SizeBytes
+	}
+	return 0
+}
+
+func (m *ConvertedBlob) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+func (m *ConvertedBlob) GetContentEncoding() string {
+	if m != nil {
+		return m.ContentEncoding
+	}
+	return ""
+}
+
+func (m *ConvertedBlob) GetAttributes() map[string]string {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
+func (m *ConvertedBlob) GetFrameSizeBytes() int64 {
+	if m != nil {
+		return m.FrameSizeBytes
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterEnum("messaging.v1.Location_Kind", Location_Kind_name, Location_Kind_value)
 	proto.RegisterType((*Location)(nil), "messaging.v1.Location")
 	proto.RegisterType((*InputFrame)(nil), "messaging.v1.InputFrame")
+	proto.RegisterMapType((map[string]string)(nil), "messaging.v1.InputFrame.AttributesEntry")
 	proto.RegisterType((*ConvertedBlob)(nil), "messaging.v1.ConvertedBlob")
+	proto.RegisterMapType((map[string]string)(nil), "messaging.v1.ConvertedBlob.AttributesEntry")
 }
 
 func init() { proto.RegisterFile("api/v1/messaging.proto", fileDescriptor_bfe8346b9862b125) }
 
 var fileDescriptor_bfe8346b9862b125 = []byte{
-	// 284 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x4b, 0x2c, 0xc8, 0xd4,
-	0x2f, 0x33, 0xd4, 0xcf, 0x4d, 0x2d, 0x2e, 0x4e, 0x4c, 0xcf, 0xcc, 0x4b, 0xd7, 0x2b, 0x28, 0xca,
-	0x2f, 0xc9, 0x17, 0xe2, 0x41, 0x08, 0x94, 0x19, 0x4a, 0x89, 0xa4, 0xe7, 0xa7, 0xe7, 0x83, 0x25,
-	0xf4, 0x41, 0x2c, 0x88, 0x1a, 0xa5, 0x76, 0x46, 0x2e, 0x0e, 0x9f, 0xfc, 0xe4, 0xc4, 0x92, 0xcc,
-	0xfc, 0x3c, 0x21, 0x7d, 0x2e, 0x96, 0xec, 0xcc, 0xbc, 0x14, 0x09, 0x46, 0x05, 0x46, 0x0d, 0x3e,
-	0x23, 0x69, 0x3d, 0x64, 0xfd, 0x7a, 0x30, 0x55, 0x7a, 0xde, 0x99, 0x79, 0x29, 0x41, 0x60, 0x85,
-	0x42, 0x62, 0x5c, 0x6c, 0x49, 0xa5, 0xc9, 0xd9, 0xa9, 0x25, 0x12, 0x4c, 0x0a, 0x8c, 0x1a, 0x9c,
-	0x41, 0x50, 0x9e, 0x90, 0x3c, 0x17, 0x77, 0x7e, 0x52, 0x56, 0x6a, 0x72, 0x49, 0x7c, 0x5e, 0x62,
-	0x6e, 0xaa, 0x04, 0x33, 0x58, 0x92, 0x0b, 0x22, 0xe4, 0x97, 0x98, 0x9b, 0xaa, 0x24, 0xc8, 0xc5,
-	0x02, 0x32, 0x46, 0x88, 0x93, 0x8b, 0xd5, 0xd7, 0xd3, 0xcf, 0xd3, 0x5f, 0x80, 0x41, 0x29, 0x8d,
-	0x8b, 0xcb, 0x33, 0xaf, 0xa0, 0xb4, 0xc4, 0xad, 0x28, 0x31, 0x37, 0x55, 0x48, 0x92, 0x8b, 0x23,
-	0x0d, 0xc4, 0x88, 0xcf, 0x84, 0x38, 0x87, 0x33, 0x88, 0x1d, 0xcc, 0xf7, 0x4c, 0x11, 0xb2, 0xe5,
-	0xe2, 0x83, 0x48, 0xe5, 0x40, 0x5d, 0x04, 0xb6, 0x9c, 0xdb, 0x48, 0x0c, 0xbb, 0x7b, 0x83, 0x78,
-	0xc1, 0xaa, 0x61, 0x5c, 0xa5, 0x0d, 0x8c, 0x5c, 0xbc, 0xce, 0xf9, 0x79, 0x65, 0xa9, 0x45, 0x25,
-	0xa9, 0x29, 0x4e, 0x39, 0xf9, 0x49, 0xb4, 0xb3, 0x4b, 0xc8, 0x95, 0x4b, 0x28, 0x19, 0x66, 0x15,
-	0xc2, 0x08, 0x66, 0xbc, 0x46, 0x08, 0xc2, 0x75, 0xc0, 0x84, 0x9c, 0x78, 0x4e, 0x3c, 0x92, 0x63,
-	0xbc, 0xf0, 0x48, 0x8e, 0xf1, 0xc1, 0x23, 0x39, 0xc6, 0x24, 0x36, 0x70, 0xcc, 0x19, 0x03, 0x02,
-	0x00, 0x00, 0xff, 0xff, 0x69, 0x21, 0x94, 0xcd, 0xf7, 0x01, 0x00, 0x00,
+	// 504 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x53, 0xdd, 0x8e, 0xd2, 0x40,
+	0x14, 0x76, 0x28, 0xb0, 0xf4, 0xc0, 0xb2, 0x38, 0xd9, 0x90, 0x8a, 0x11, 0x81, 0xab, 0x1a, 0x13,
+	0xc8, 0xe2, 0x8d, 0x31, 0xd9, 0x0b, 0x31, 0x6c, 0x24, 0xbb, 0xac, 0x49, 0xd9, 0x1b, 0xaf, 0x9a,
+	0xfe, 0x8c, 0x75, 0x84, 0xce, 0x34, 0xed, 0x94, 0xa4, 0xbe, 0x88, 0xaf, 0xe4, 0x8d, 0x89, 0x8f,
+	0x60, 0x78, 0x01, 0x5f, 0xc1, 0x74, 0x5a, 0x0a, 0x6c, 0x36, 0xdc, 0x98, 0xbd, 0x9b, 0xf3, 0x9d,
+	0x6f, 0xce, 0x7c, 0xe7, 0xfb, 0x32, 0xd0, 0xb6, 0x02, 0x3a, 0x5a, 0x5f, 0x8c, 0x7c, 0x12, 0x45,
+	0x96, 0x47, 0x99, 0x37, 0x0c, 0x42, 0x2e, 0x38, 0x6e, 0xec, 0x80, 0xf5, 0x45, 0xe7, 0xdc, 0xe3,
+	0x1e, 0x97, 0x8d, 0x51, 0x7a, 0xca, 0x38, 0x83, 0x1f, 0x08, 0x6a, 0x37, 0xdc, 0xb1, 0x04, 0xe5,
+	0x0c, 0x8f, 0xa0, 0xbc, 0xa4, 0xcc, 0xd5, 0x50, 0x0f, 0xe9, 0xcd, 0xf1, 0xf3, 0xe1, 0xfe, 0xfd,
+	0xe1, 0x96, 0x35, 0xbc, 0xa6, 0xcc, 0x35, 0x24, 0x11, 0xb7, 0xa1, 0x6a, 0xc7, 0xce, 0x92, 0x08,
+	0xad, 0xd4, 0x43, 0xba, 0x6a, 0xe4, 0x15, 0x7e, 0x09, 0x75, 0x6e, 0x7f, 0x23, 0x8e, 0x30, 0x99,
+	0xe5, 0x13, 0x4d, 0x91, 0x4d, 0xc8, 0xa0, 0x5b, 0xcb, 0x27, 0x83, 0x3e, 0x94, 0xd3, 0x31, 0x58,
+	0x85, 0xca, 0x7c, 0x76, 0x3b, 0xfb, 0xd4, 0x7a, 0x82, 0x9b, 0x00, 0x57, 0xb3, 0x9b, 0xe9, 0xe2,
+	0xf3, 0xe2, 0x6e, 0x3a, 0x6f, 0xa1, 0xc1, 0xaf, 0x12, 0xc0, 0x8c, 0x05, 0xb1, 0xb8, 0x0a, 0x2d,
+	0x9f, 0xe0, 0x67, 0x50, 0xfb, 0x92, 0x1e, 0x4c, 0x9a, 0xe9, 0x53, 0x8d, 0x13, 0x59, 0xcf, 0x5c,
+	0x7c, 0x09, 0xcd, 0xac, 0xb5, 0xca, 0x25, 0x4a, 0x35, 0xf5, 0x71, 0xfb, 0xe1, 0x05, 0x8c, 0x53,
+	0xc9, 0x2e, 0xb6, 0xee, 0x40, 0xcd, 0xf9, 0x4a, 0x9c, 0x65, 0x14, 0xfb, 0xb9, 0xd2, 0xa2, 0xc6,
+	0x2f, 0x00, 0x22, 0xfa, 0x9d, 0x98, 0x76, 0x22, 0x48, 0xa4, 0x95, 0x7b, 0x48, 0x57, 0x0c, 0x35,
+	0x45, 0x26, 0x29, 0x80, 0x3f, 0x02, 0x58, 0x42, 0x84, 0xd4, 0x8e, 0xd3, 0x76, 0xa5, 0xa7, 0xe8,
+	0xf5, 0xb1, 0x7e, 0xf8, 0xea, 0x6e, 0x85, 0xe1, 0xfb, 0x82, 0x3a, 0x65, 0x22, 0x4c, 0x8c, 0xbd,
+	0xbb, 0xb8, 0x0f, 0x0d, 0x41, 0x7d, 0x12, 0x09, 0xcb, 0x0f, 0x4c, 0x3f, 0xd2, 0xaa, 0xf2, 0xa9,
+	0x7a, 0x81, 0xcd, 0xa3, 0xce, 0x25, 0x9c, 0xdd, 0x9b, 0x80, 0x5b, 0xa0, 0x2c, 0x49, 0x92, 0xfb,
+	0x91, 0x1e, 0xf1, 0x39, 0x54, 0xd6, 0xd6, 0x2a, 0x26, 0x79, 0x20, 0x59, 0xf1, 0xae, 0xf4, 0x16,
+	0x0d, 0xfe, 0x2a, 0x70, 0xfa, 0x81, 0xb3, 0x35, 0x09, 0x05, 0x71, 0x27, 0x2b, 0x6e, 0x3f, 0xa2,
+	0xa5, 0x53, 0xc0, 0xce, 0xf6, 0xa9, 0xdd, 0x08, 0xe5, 0xe8, 0x88, 0xa7, 0xc5, 0x8d, 0x07, 0x93,
+	0x29, 0x1f, 0x4d, 0xa6, 0x72, 0x3f, 0x99, 0x3e, 0x34, 0x1c, 0xce, 0x04, 0x61, 0xc2, 0x14, 0x49,
+	0x40, 0xa4, 0x9f, 0xaa, 0x51, 0xcf, 0xb1, 0xbb, 0x24, 0x20, 0xf8, 0x15, 0xb4, 0xb6, 0x14, 0xc2,
+	0x1c, 0xee, 0x52, 0xe6, 0x69, 0x27, 0x92, 0x76, 0x96, 0xe3, 0xd3, 0x1c, 0xc6, 0xd7, 0x07, 0x39,
+	0xd7, 0x64, 0xce, 0xaf, 0x0f, 0xf7, 0x38, 0xb0, 0xf6, 0x68, 0xd4, 0x3a, 0xb4, 0x32, 0x6f, 0xf7,
+	0xf4, 0xab, 0x52, 0x7f, 0xe6, 0xf9, 0x62, 0xbb, 0xc4, 0x7f, 0x26, 0x3e, 0x69, 0xfc, 0xdc, 0x74,
+	0xd1, 0xef, 0x4d, 0x17, 0xfd, 0xd9, 0x74, 0x91, 0x5d, 0x95, 0x1f, 0xfe, 0xcd, 0xbf, 0x01, 0x00,
+	0x2e, 0x51, 0x03, 0x77, 0x2e, 0x04, 0x00, 0x00,
 }
 
 func (m *Location) Marshal() (dAtA []byte, err error) {
@@ -343,6 +460,42 @@ func (m *InputFrame) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.TimestampMs != 0 {
+		i = encodeVarintMessaging(dAtA, i, uint64(m.TimestampMs))
+		i--
+		dAtA[i] = 0x30
+	}
+	if len(m.Attributes) > 0 {
+		for k := range m.Attributes {
+			v := m.Attributes[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintMessaging(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintMessaging(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintMessaging(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x2a
+		}
+	}
+	if m.SizeBytes != 0 {
+		i = encodeVarintMessaging(dAtA, i, uint64(m.SizeBytes))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.Checksum) > 0 {
+		i -= len(m.Checksum)
+		copy(dAtA[i:], m.Checksum)
+		i = encodeVarintMessaging(dAtA, i, uint64(len(m.Checksum)))
+		i--
+		dAtA[i] = 0x1a
+	}
 	if m.FrameLocation != nil {
 		{
 			size, err := m.FrameLocation.MarshalToSizedBuffer(dAtA[:i])
@@ -389,6 +542,56 @@ func (m *ConvertedBlob) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.FrameSizeBytes != 0 {
+		i = encodeVarintMessaging(dAtA, i, uint64(m.FrameSizeBytes))
+		i--
+		dAtA[i] = 0x48
+	}
+	if len(m.Attributes) > 0 {
+		for k := range m.Attributes {
+			v := m.Attributes[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintMessaging(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintMessaging(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintMessaging(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x42
+		}
+	}
+	if len(m.ContentEncoding) > 0 {
+		i -= len(m.ContentEncoding)
+		copy(dAtA[i:], m.ContentEncoding)
+		i = encodeVarintMessaging(dAtA, i, uint64(len(m.ContentEncoding)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.ContentType) > 0 {
+		i -= len(m.ContentType)
+		copy(dAtA[i:], m.ContentType)
+		i = encodeVarintMessaging(dAtA, i, uint64(len(m.ContentType)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.SizeBytes != 0 {
+		i = encodeVarintMessaging(dAtA, i, uint64(m.SizeBytes))
+		i--
+		dAtA[i] = 0x28
+	}
+	if len(m.Checksum) > 0 {
+		i -= len(m.Checksum)
+		copy(dAtA[i:], m.Checksum)
+		i = encodeVarintMessaging(dAtA, i, uint64(len(m.Checksum)))
+		i--
+		dAtA[i] = 0x22
+	}
 	if m.ConvertedLocation != nil {
 		{
 			size, err := m.ConvertedLocation.MarshalToSizedBuffer(dAtA[:i])
@@ -471,6 +674,24 @@ func (m *InputFrame) Size() (n int) {
 		l = m.FrameLocation.Size()
 		n += 1 + l + sovMessaging(uint64(l))
 	}
+	l = len(m.Checksum)
+	if l > 0 {
+		n += 1 + l + sovMessaging(uint64(l))
+	}
+	if m.SizeBytes != 0 {
+		n += 1 + sovMessaging(uint64(m.SizeBytes))
+	}
+	if len(m.Attributes) > 0 {
+		for k, v := range m.Attributes {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovMessaging(uint64(len(k))) + 1 + len(v) + sovMessaging(uint64(len(v)))
+			n += mapEntrySize + 1 + sovMessaging(uint64(mapEntrySize))
+		}
+	}
+	if m.TimestampMs != 0 {
+		n += 1 + sovMessaging(uint64(m.TimestampMs))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -495,6 +716,32 @@ func (m *ConvertedBlob) Size() (n int) {
 		l = m.ConvertedLocation.Size()
 		n += 1 + l + sovMessaging(uint64(l))
 	}
+	l = len(m.Checksum)
+	if l > 0 {
+		n += 1 + l + sovMessaging(uint64(l))
+	}
+	if m.SizeBytes != 0 {
+		n += 1 + sovMessaging(uint64(m.SizeBytes))
+	}
+	l = len(m.ContentType)
+	if l > 0 {
+		n += 1 + l + sovMessaging(uint64(l))
+	}
+	l = len(m.ContentEncoding)
+	if l > 0 {
+		n += 1 + l + sovMessaging(uint64(l))
+	}
+	if len(m.Attributes) > 0 {
+		for k, v := range m.Attributes {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovMessaging(uint64(len(k))) + 1 + len(v) + sovMessaging(uint64(len(v)))
+			n += mapEntrySize + 1 + sovMessaging(uint64(mapEntrySize))
+		}
+	}
+	if m.FrameSizeBytes != 0 {
+		n += 1 + sovMessaging(uint64(m.FrameSizeBytes))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -741,6 +988,203 @@ func (m *InputFrame) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Checksum", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Checksum = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SizeBytes", wireType)
+			}
+			m.SizeBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SizeBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Attributes", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Attributes == nil {
+				m.Attributes = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowMessaging
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowMessaging
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowMessaging
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipMessaging(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Attributes[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimestampMs", wireType)
+			}
+			m.TimestampMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TimestampMs |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessaging(dAtA[iNdEx:])
@@ -899,6 +1343,267 @@ func (m *ConvertedBlob) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Checksum", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Checksum = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SizeBytes", wireType)
+			}
+			m.SizeBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SizeBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ContentType", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ContentType = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ContentEncoding", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ContentEncoding = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Attributes", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessaging
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Attributes == nil {
+				m.Attributes = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowMessaging
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowMessaging
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowMessaging
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipMessaging(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthMessaging
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Attributes[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FrameSizeBytes", wireType)
+			}
+			m.FrameSizeBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessaging
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FrameSizeBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessaging(dAtA[iNdEx:])
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	// envPrefix is the prefix of the environment variables
+	// that override the configuration, e.g. DATAPIPE_STORAGE_ENDPOINT.
+	envPrefix = "DATAPIPE"
+
+	// configFileKey is the flag (and the configuration key)
+	// that points to the configuration file.
+	configFileKey = "config"
+)
+
+// bindFlags defines the command line flags for every configuration key.
+//
+// The flag defaults are the defaults of the configuration,
+// the flag names are the configuration keys, so the flags
+// could be bound to viper as is.
+func bindFlags(flags *pflag.FlagSet) {
+	flags.String(configFileKey, "", "Path to the configuration file (yaml, toml, json).")
+
+	// Logger
+	flags.String("logger.level", "info", "Log level: trace, debug, info, warning, error, fatal, panic.")
+	flags.String("logger.formatter", "json", "Log formatter: text, json.")
+
+	// Storage
+	flags.String("storage.endpoint", "localhost:9000", "Storage endpoint.")
+	flags.Bool("storage.usessl", false, "Use SSL to connect to the storage.")
+	flags.String("storage.accesskey", "", "Storage access key.")
+	flags.String("storage.secretkey", "", "Storage secret key.")
+	flags.String("storage.accesskeyfile", "", "File with the storage access key.")
+	flags.String("storage.secretkeyfile", "", "File with the storage secret key.")
+	flags.String("storage.region", "", "Storage region of the created buckets.")
+	flags.Bool("storage.createbucketifnotexist", false, "Create the destination bucket if it doesn't exist.")
+	flags.String("filesystem.root", "", "Root directory of the local storage, disabled if empty.")
+	flags.Bool("filesystem.createbucketifnotexist", false, "Create the destination bucket directory if it doesn't exist.")
+
+	// Processor
+	flags.String("processor.destinationbucket", "", "Bucket for the converted blobs.")
+	flags.String("processor.destinationkind", "minio", "Storage for the converted blobs: minio, filesystem.")
+	flags.String("processor.objectname", "converted_{{.FrameID}}.blob", "Template of the converted blob object name.")
+	flags.Bool("processor.idempotent", false, "Skip the data frames that have already been converted.")
+	flags.Bool("processor.requirechecksum", false, "Reject the data frames that have no checksum.")
+	flags.Bool("processor.detectcontenttype", false, "Detect the content type of the converted blobs, if the converter doesn't declare it.")
+	flags.StringSlice("processor.metadataattributes", nil, "Data frame attributes that are stored as the converted blob metadata.")
+	flags.String("converter.kind", "identity", "Kind of the data frame converter: identity, gzip, gunzip, chain.")
+
+	// Reader
+	flags.StringSlice("reader.brokers", nil, "Kafka brokers of the input stream.")
+	flags.String("reader.groupid", "data-pipe", "Kafka consumer group of the input stream.")
+	flags.String("reader.topic", "", "Kafka topic of the input stream.")
+	flags.Bool("reader.createifnotexist", false, "Create the input topic if it doesn't exist.")
+	flags.Int("reader.numpartitions", 1, "Number of partitions of the created input topic.")
+	flags.Int("reader.replicationfactor", 1, "Replication factor of the created input topic.")
+	flags.Int("reader.minbytes", 1, "Minimum batch size that the reader accepts.")
+	flags.Int("reader.maxbytes", 10e6, "Maximum batch size that the reader accepts.")
+
+	// Writer
+	flags.String("writer.addr", "", "Kafka broker of the output stream.")
+	flags.String("writer.balancer", "leastbytes", "Partition balancer: roundrobin, leastbytes, hash, crc32, murmur2.")
+	flags.String("writer.topic", "", "Kafka topic of the output stream.")
+	flags.Bool("writer.createifnotexist", false, "Create the output topic if it doesn't exist.")
+	flags.Int("writer.numpartitions", 1, "Number of partitions of the created output topic.")
+	flags.Int("writer.replicationfactor", 1, "Replication factor of the created output topic.")
+
+	// Dead letter writer
+	flags.String("deadletter.addr", "", "Kafka broker of the dead letter stream.")
+	flags.String("deadletter.balancer", "leastbytes", "Partition balancer of the dead letter stream.")
+	flags.String("deadletter.topic", "", "Kafka topic of the dead letter stream, disabled if empty.")
+	flags.Bool("deadletter.createifnotexist", false, "Create the dead letter topic if it doesn't exist.")
+	flags.Int("deadletter.numpartitions", 1, "Number of partitions of the created dead letter topic.")
+	flags.Int("deadletter.replicationfactor", 1, "Replication factor of the created dead letter topic.")
+
+	// Metrics and status
+	flags.String("metrics.addr", ":9090", "Address of the prometheus server.")
+	flags.String("metrics.path", "/metrics", "Path of the prometheus metrics.")
+	flags.String("metrics.namespace", "", "Namespace prefix of the metric names.")
+	flags.String("metrics.subsystem", "", "Subsystem prefix of the metric names.")
+	flags.String("status.rpcaddr", ":8081", "Address of the gRPC health server.")
+	flags.String("service.name", "data-pipe", "Service label of the reported metrics.")
+	flags.String("service.instance", "", "Instance label of the reported metrics, omitted if empty.")
+	flags.String("service.engine", "data-pipe", "Engine label of the reported metrics.")
+
+	// Tracing
+	flags.String("tracing.endpoint", "", "Address of the OTLP gRPC collector, tracing is disabled if empty.")
+	flags.Bool("tracing.insecure", false, "Connect to the OTLP collector without TLS.")
+	flags.Float64("tracing.sampleratio", 1.0, "Ratio of the sampled traces that are started by the service.")
+
+	// Pipeline
+	flags.Int("pipeline.count", 1, "Number of concurrently running pipelines.")
+	flags.Int("pipeline.concurrency", 1, "Number of data frames that are handled concurrently by a pipeline.")
+	flags.Duration("pipeline.backoff", 100*time.Millisecond, "Initial backoff of the failed operations.")
+	flags.Duration("pipeline.maxbackoff", 10*time.Second, "Maximum backoff of the failed operations.")
+	flags.Duration("pipeline.shutdowntimeout", 10*time.Second, "Graceful shutdown timeout.")
+	flags.Duration("pipeline.restartbackoff", time.Second, "Initial backoff before a failed pipeline is restarted.")
+	flags.Duration("pipeline.maxrestartbackoff", time.Minute, "Maximum backoff before a failed pipeline is restarted.")
+	flags.Duration("pipeline.graceperiod", 20*time.Second, "Time given to the in-flight data frames to finish on shutdown.")
+	flags.Duration("pipeline.draintimeout", 30*time.Second, "Time given to the pipelines to stop.")
+	flags.Int("pipeline.batchsize", 1, "Maximum number of converted blobs that are written and committed together.")
+	flags.Duration("pipeline.batchtimeout", 100*time.Millisecond, "Maximum time a converted blob waits in the batch.")
+}
+
+// loadConfig populates the configuration from the defaults,
+// the configuration file, the environment variables and the flags.
+//
+// The sources are applied in the order of precedence, from the lowest to the highest:
+//   - flag defaults
+//   - configuration file
+//   - environment variables
+//   - explicitly set flags
+func loadConfig(v *viper.Viper, flags *pflag.FlagSet) (cfg, error) {
+	var c cfg
+
+	if err := v.BindPFlags(flags); err != nil {
+		return c, err
+	}
+
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if file := v.GetString(configFileKey); file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return c, err
+		}
+	}
+
+	if err := v.Unmarshal(&c); err != nil {
+		return c, err
+	}
+
+	if err := loadSecrets(&c); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// loadSecrets reads the secrets from the files,
+// if the secret files are configured.
+// The secret file takes precedence over the secret value.
+func loadSecrets(c *cfg) error {
+	for _, s := range []struct {
+		file  string
+		value *string
+	}{
+		{c.Storage.AccessKeyFile, &c.Storage.AccessKey},
+		{c.Storage.SecretKeyFile, &c.Storage.SecretKey},
+	} {
+		if s.file == "" {
+			continue
+		}
+
+		secret, err := os.ReadFile(s.file)
+		if err != nil {
+			return err
+		}
+		*s.value = strings.TrimSpace(string(secret))
+	}
+	return nil
+}
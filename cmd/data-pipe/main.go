@@ -1,37 +1,432 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"io"
 	"log"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/metrics"
+	"github.com/weak-head/data-pipe/internal/pipeline"
+	"github.com/weak-head/data-pipe/internal/processor"
+	"github.com/weak-head/data-pipe/internal/sleeper"
+	"github.com/weak-head/data-pipe/internal/status"
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/internal/stream"
+	"github.com/weak-head/data-pipe/internal/tracing"
+	"github.com/weak-head/data-pipe/internal/validation"
+)
+
+var (
+	// ErrNoPipelines happens when the service is configured
+	// to run zero pipelines.
+	ErrNoPipelines = errors.New("at least one pipeline should be configured")
+
+	// ErrInvalidMaxBackoff happens when the maximum restart backoff
+	// is less than the initial restart backoff.
+	ErrInvalidMaxBackoff = errors.New("should not be less than restartbackoff")
+
+	// ErrInvalidMaxRetryBackoff happens when the maximum backoff
+	// is less than the initial backoff.
+	ErrInvalidMaxRetryBackoff = errors.New("should not be less than backoff")
+
+	// ErrInvalidDrainTimeout happens when the pipelines are stopped
+	// before the in-flight data frames are drained.
+	ErrInvalidDrainTimeout = errors.New("should be greater than graceperiod")
+
+	// ErrNegativeDuration happens when the duration is negative.
+	ErrNegativeDuration = errors.New("should not be negative")
+)
+
+const (
+	// pipelineHealthService is the health service, that is reported
+	// as serving while all pipelines are running.
+	pipelineHealthService = "pipeline"
+
+	// pipelineHealthInterval defines how often the pipeline statuses are checked.
+	pipelineHealthInterval = time.Second
 )
 
 type cli struct {
 	cfg cfg
+	v   *viper.Viper
+}
+
+// PipelineConfig defines how many pipelines are started
+// and how they behave on failures and shutdown.
+type PipelineConfig struct {
+	pipeline.Config           `mapstructure:",squash"`
+	pipeline.SupervisorConfig `mapstructure:",squash"`
+
+	// Number of pipelines that run concurrently
+	// in a single instance of the service.
+	Count int
+
+	// Initial duration of the exponential backoff
+	// that is used by a pipeline to retry failed operations.
+	Backoff time.Duration
+
+	// Maximum duration of the exponential backoff.
+	MaxBackoff time.Duration
+
+	// Time given to the service components
+	// to shut down gracefully.
+	ShutdownTimeout time.Duration
 }
 
 type cfg struct {
+	processor.Config `mapstructure:",squash"`
+
+	Logger   logger.Config
+	Reader   stream.ReaderConfig
+	Writer   stream.WriterConfig
+	Metrics  metrics.Config
+	Status   status.Config
+	Service  metrics.ServiceInfo
+	Tracing  tracing.Config
+	Pipeline PipelineConfig
+
+	// DeadLetter is the writer of the messages that pipelines fail to handle.
+	// The dead letter writer is disabled if the topic is not configured.
+	DeadLetter stream.WriterConfig
+}
+
+// Validate returns the aggregated validation failures of the service configuration.
+// The failures are reported with the configuration keys of the failed fields.
+func (c cfg) Validate() error {
+	var errs validation.Errors
+	errs.Merge("", c.Config.Validate())
+	errs.Merge("logger", c.Logger.Validate())
+	errs.Merge("reader", c.Reader.Validate())
+	errs.Merge("writer", c.Writer.Validate())
+	errs.Merge("metrics", c.Metrics.Validate())
+	errs.Merge("status", c.Status.Validate())
+	errs.Merge("pipeline", c.Pipeline.Validate())
+	if c.DeadLetter.Topic != "" {
+		errs.Merge("deadletter", c.DeadLetter.Validate())
+	}
+	if c.Tracing.Endpoint != "" {
+		errs.Merge("tracing", c.Tracing.Validate())
+	}
+	return errs.Err()
+}
+
+// Validate returns the aggregated validation failures of the pipeline configuration.
+func (c PipelineConfig) Validate() error {
+	var errs validation.Errors
+	if c.Count <= 0 {
+		errs.Add("count", ErrNoPipelines)
+	}
+	errs.Add("concurrency", validation.Positive(c.Concurrency))
+	errs.Add("batchsize", validation.Positive(c.BatchSize))
+	errs.Add("batchtimeout", positiveDuration(c.BatchTimeout))
+	errs.Add("backoff", positiveDuration(c.Backoff))
+	if c.MaxBackoff < c.Backoff {
+		errs.Add("maxbackoff", ErrInvalidMaxRetryBackoff)
+	}
+	errs.Add("shutdowntimeout", positiveDuration(c.ShutdownTimeout))
+	errs.Add("restartbackoff", positiveDuration(c.RestartBackoff))
+	if c.GracePeriod < 0 {
+		errs.Add("graceperiod", ErrNegativeDuration)
+	}
+	if c.DrainTimeout > 0 && c.DrainTimeout <= c.GracePeriod {
+		errs.Add("draintimeout", ErrInvalidDrainTimeout)
+	}
+	if c.MaxRestartBackoff < c.RestartBackoff {
+		errs.Add("maxrestartbackoff", ErrInvalidMaxBackoff)
+	}
+	return errs.Err()
+}
+
+func positiveDuration(d time.Duration) error {
+	if d <= 0 {
+		return validation.ErrNotPositive
+	}
+	return nil
 }
 
 func (c *cli) initConfig(cmd *cobra.Command, args []string) error {
+	conf, err := loadConfig(c.v, cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	c.cfg = conf
 	return nil
 }
 
 func (c *cli) run(cmd *cobra.Command, args []string) error {
+	lg, err := logger.New(c.cfg.Logger)
+	if err != nil {
+		return err
+	}
 
-	fmt.Print("Running")
+	log := lg.WithFields(logger.Fields{
+		logger.FieldService:  "data-pipe",
+		logger.FieldFunction: "cli.run",
+	})
 
-	return nil
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The tracer provider is set before the processor and pipelines
+	// are created, so they trace with the configured provider.
+	if c.cfg.Tracing.Endpoint != "" {
+		provider, err := tracing.NewProvider(ctx, c.cfg.Tracing, "data-pipe", lg)
+		if err != nil {
+			return err
+		}
+		otel.SetTracerProvider(provider)
+
+		defer func() {
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), c.cfg.Pipeline.ShutdownTimeout)
+			defer shutdownCancel()
+
+			if err := provider.Shutdown(shutdownCtx); err != nil {
+				log.Error(err, "Failed to shut down the tracer provider.")
+			}
+		}()
+	}
+
+	storages, err := c.newStorages(lg)
+	if err != nil {
+		return err
+	}
+
+	reporter, err := metrics.NewReporter(c.cfg.Metrics, c.cfg.Service)
+	if err != nil {
+		return err
+	}
+
+	converter, err := processor.NewConverter(c.cfg.Converter, lg)
+	if err != nil {
+		return err
+	}
+	converter = processor.WithStepReporter(converter, reporter)
+
+	processorConfig := c.cfg.Processor
+	processorConfig.ConverterKind = c.cfg.Converter.Kind
+
+	proc, err := processor.NewProcessor(processorConfig, converter, storages, lg)
+	if err != nil {
+		return err
+	}
+
+	promServer, err := metrics.NewPrometheusServer(c.cfg.Metrics, reporter)
+	if err != nil {
+		return err
+	}
+
+	statusServer, err := status.NewStatusServer()
+	if err != nil {
+		return err
+	}
+
+	// Each pipeline is created with the new readers and writers
+	// every time it is started or restarted by the supervisor.
+	var pipelines []pipeline.RunnerFactory
+	for i := 0; i < c.cfg.Pipeline.Count; i++ {
+		pipelines = append(pipelines, func() (pipeline.Runner, error) {
+			return c.newPipeline(proc, reporter, lg)
+		})
+	}
+
+	supervisor, err := pipeline.NewSupervisor(c.cfg.Pipeline.SupervisorConfig, pipelines, lg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		runErr  error
+		errOnce sync.Once
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			runErr = err
+			cancel()
+		})
+	}
+
+	go func() {
+		if err := promServer.Serve(); err != nil {
+			log.Error(err, "Prometheus server has failed.")
+			fail(err)
+		}
+	}()
+
+	go func() {
+		if err := statusServer.Serve(c.cfg.Status); err != nil {
+			log.Error(err, "Status server has failed.")
+			fail(err)
+		}
+	}()
+
+	go statusServer.Watch(ctx, pipelineHealthService, pipelineHealthInterval, func() bool {
+		return allRunning(supervisor.Statuses())
+	})
+
+	// The supervisor runs the pipelines until the service is stopped,
+	// and then waits for the pipelines to drain. Each pipeline closes
+	// its readers and writers only once it is stopped, so the streams
+	// of the pipelines that have not stopped within the drain timeout are left open.
+	supervised := make(chan error, 1)
+	go func() { supervised <- supervisor.Run(ctx) }()
+
+	<-ctx.Done()
+	log.Info("Shutting down the service.")
+
+	// Ignore the failures that are caused by the shutdown itself.
+	errOnce.Do(func() {})
+
+	if err := <-supervised; err != nil {
+		log.Error(err, "Failed to stop the pipelines.")
+	}
+
+	statusServer.Stop()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), c.cfg.Pipeline.ShutdownTimeout)
+	defer shutdownCancel()
+
+	if err := promServer.Stop(shutdownCtx); err != nil {
+		log.Error(err, "Failed to stop the prometheus server.")
+	}
+
+	return runErr
+}
+
+// newStorages creates the storages of the supported location kinds.
+// The filesystem storage is created only if it is configured.
+func (c *cli) newStorages(log logger.Log) (processor.Storages, error) {
+	minio, err := storage.NewMinioStorage(c.cfg.Storage, log)
+	if err != nil {
+		return nil, err
+	}
+
+	storages := processor.Storages{api.Location_MINIO: minio}
+
+	if c.cfg.Filesystem.Root != "" {
+		filesystem, err := storage.NewFilesystemStorage(c.cfg.Filesystem, log)
+		if err != nil {
+			return nil, err
+		}
+		storages[api.Location_FILESYSTEM] = filesystem
+	}
+
+	return storages, nil
+}
+
+// allRunning returns true if all supervised pipelines are running.
+func allRunning(statuses []pipeline.Status) bool {
+	for _, st := range statuses {
+		if st.State != pipeline.StateRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// pipelineRunner is the pipeline, that closes its readers and writers
+// once the pipeline is stopped.
+type pipelineRunner struct {
+	*pipeline.Pipeline
+	closers []io.Closer
+}
+
+// Close closes the readers and writers of the pipeline.
+func (r *pipelineRunner) Close() error {
+	return closeAll(r.closers)
+}
+
+// closeAll closes all closers and returns the first error.
+func closeAll(closers []io.Closer) error {
+	var firstErr error
+	for _, cl := range closers {
+		if err := cl.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// newPipeline creates a new pipeline with a dedicated stream reader,
+// writer and sleeper. The created readers and writers are closed
+// if the pipeline creation has failed.
+func (c *cli) newPipeline(
+	proc pipeline.Processor,
+	reporter pipeline.Reporter,
+	log logger.Log,
+) (r pipeline.Runner, err error) {
+	var closers []io.Closer
+	defer func() {
+		if err != nil {
+			closeAll(closers)
+		}
+	}()
+
+	reader, err := stream.NewReader(c.cfg.Reader)
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, reader)
+
+	writer, err := stream.NewWriter(c.cfg.Writer)
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, writer)
+
+	var deadLetter pipeline.Writer
+	if c.cfg.DeadLetter.Topic != "" {
+		dl, err := stream.NewWriter(c.cfg.DeadLetter)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, dl)
+		deadLetter = dl
+	}
+
+	pipelineConfig := c.cfg.Pipeline.Config
+	pipelineConfig.ConverterKind = c.cfg.Converter.Kind
+
+	newSleeper := func() pipeline.Sleeper {
+		return sleeper.NewExponentialSleeper(c.cfg.Pipeline.Backoff, c.cfg.Pipeline.MaxBackoff)
+	}
+
+	p, err := pipeline.NewPipeline(pipelineConfig, reader, writer, deadLetter, proc, newSleeper, reporter, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pipelineRunner{Pipeline: p, closers: closers}, nil
 }
 
 func main() {
-	cli := &cli{}
+	cli := &cli{
+		v: viper.New(),
+	}
 	cmd := &cobra.Command{
 		Use:     "data-pipe",
 		PreRunE: cli.initConfig,
 		RunE:    cli.run,
 	}
+	bindFlags(cmd.Flags())
 
 	if err := cmd.Execute(); err != nil {
 		log.Fatal(err)
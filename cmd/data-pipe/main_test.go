@@ -1 +1,185 @@
 package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/processor"
+	"github.com/weak-head/data-pipe/internal/validation"
+)
+
+func TestConfigLoading(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		v *viper.Viper,
+		flags *pflag.FlagSet,
+		dir string,
+	){
+		"uses flag defaults":                     testConfigDefaults,
+		"config file overrides defaults":         testConfigFileOverridesDefaults,
+		"environment overrides config file":      testConfigEnvOverridesFile,
+		"flags override environment":             testConfigFlagsOverrideEnv,
+		"secrets are loaded from files":          testConfigSecretsFromFiles,
+		"fails if the config file doesn't exist": testConfigFailsOnMissingFile,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			flags := pflag.NewFlagSet("data-pipe", pflag.ContinueOnError)
+			bindFlags(flags)
+
+			fn(t, viper.New(), flags, t.TempDir())
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func testConfigDefaults(t *testing.T, v *viper.Viper, flags *pflag.FlagSet, dir string) {
+	c, err := loadConfig(v, flags)
+	require.NoError(t, err)
+
+	require.Equal(t, "info", c.Logger.Level)
+	require.Equal(t, "json", c.Logger.Formatter)
+	require.Equal(t, "leastbytes", c.Writer.Balancer)
+	require.Equal(t, 1, c.Reader.NumPartitions)
+	require.Equal(t, 1, c.Pipeline.Count)
+	require.Equal(t, 100*time.Millisecond, c.Pipeline.Backoff)
+	require.Equal(t, 10*time.Second, c.Pipeline.MaxBackoff)
+	require.Equal(t, "", c.Tracing.Endpoint)
+	require.Equal(t, 1.0, c.Tracing.SampleRatio)
+}
+
+func testConfigFileOverridesDefaults(t *testing.T, v *viper.Viper, flags *pflag.FlagSet, dir string) {
+	file := writeFile(t, dir, "config.yaml", `
+storage:
+  endpoint: minio:9000
+processor:
+  destinationBucket: converted
+  objectName: "{{.Year}}/{{.Month}}/{{.FrameID}}.blob"
+  destinationRules:
+    - sourceBucket: camera-*
+      bucket: camera-blobs
+reader:
+  brokers: [kafka-1:9092, kafka-2:9092]
+  topic: frames
+pipeline:
+  count: 4
+  backoff: 2s
+converter:
+  kind: gzip
+  options:
+    level: 9
+`)
+	require.NoError(t, flags.Parse([]string{"--config", file}))
+
+	c, err := loadConfig(v, flags)
+	require.NoError(t, err)
+
+	require.Equal(t, "minio:9000", c.Storage.Endpoint)
+	require.Equal(t, "converted", c.Processor.DestinationBucket)
+	require.Equal(t, "{{.Year}}/{{.Month}}/{{.FrameID}}.blob", c.Processor.ObjectName)
+	require.Equal(t, []processor.DestinationRule{{SourceBucket: "camera-*", Bucket: "camera-blobs"}}, c.Processor.DestinationRules)
+	require.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, c.Reader.Brokers)
+	require.Equal(t, "frames", c.Reader.Topic)
+	require.Equal(t, 4, c.Pipeline.Count)
+	require.Equal(t, 2*time.Second, c.Pipeline.Backoff)
+	require.Equal(t, "gzip", c.Converter.Kind)
+	require.EqualValues(t, 9, c.Converter.Options["level"])
+	require.Equal(t, "info", c.Logger.Level)
+}
+
+func testConfigEnvOverridesFile(t *testing.T, v *viper.Viper, flags *pflag.FlagSet, dir string) {
+	file := writeFile(t, dir, "config.toml", `
+[storage]
+endpoint = "minio:9000"
+`)
+	require.NoError(t, flags.Parse([]string{"--config", file}))
+	t.Setenv("DATAPIPE_STORAGE_ENDPOINT", "minio.prod:9000")
+	t.Setenv("DATAPIPE_READER_BROKERS", "kafka-1:9092,kafka-2:9092")
+
+	c, err := loadConfig(v, flags)
+	require.NoError(t, err)
+
+	require.Equal(t, "minio.prod:9000", c.Storage.Endpoint)
+	require.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, c.Reader.Brokers)
+}
+
+func testConfigFlagsOverrideEnv(t *testing.T, v *viper.Viper, flags *pflag.FlagSet, dir string) {
+	t.Setenv("DATAPIPE_STORAGE_ENDPOINT", "minio.prod:9000")
+	require.NoError(t, flags.Parse([]string{"--storage.endpoint", "minio.local:9000"}))
+
+	c, err := loadConfig(v, flags)
+	require.NoError(t, err)
+
+	require.Equal(t, "minio.local:9000", c.Storage.Endpoint)
+}
+
+func testConfigSecretsFromFiles(t *testing.T, v *viper.Viper, flags *pflag.FlagSet, dir string) {
+	accessKey := writeFile(t, dir, "access_key", "access\n")
+	secretKey := writeFile(t, dir, "secret_key", "secret\n")
+
+	t.Setenv("DATAPIPE_STORAGE_ACCESSKEY", "plain")
+	t.Setenv("DATAPIPE_STORAGE_ACCESSKEYFILE", accessKey)
+	t.Setenv("DATAPIPE_STORAGE_SECRETKEYFILE", secretKey)
+
+	c, err := loadConfig(v, flags)
+	require.NoError(t, err)
+
+	require.Equal(t, "access", c.Storage.AccessKey)
+	require.Equal(t, "secret", c.Storage.SecretKey)
+}
+
+func testConfigFailsOnMissingFile(t *testing.T, v *viper.Viper, flags *pflag.FlagSet, dir string) {
+	require.NoError(t, flags.Parse([]string{"--config", filepath.Join(dir, "missing.yaml")}))
+
+	_, err := loadConfig(v, flags)
+	require.Error(t, err)
+}
+
+func TestConfigValidation(t *testing.T) {
+	flags := pflag.NewFlagSet("data-pipe", pflag.ContinueOnError)
+	bindFlags(flags)
+	require.NoError(t, flags.Parse([]string{
+		"--logger.formatter", "xml",
+		"--writer.balancer", "random",
+		"--writer.addr", "kafka",
+		"--reader.numpartitions", "0",
+		"--reader.topic", "frames",
+		"--writer.topic", "blobs",
+		"--tracing.endpoint", "collector",
+		"--tracing.sampleratio", "1.5",
+		"--pipeline.maxbackoff", "10ms",
+	}))
+
+	c, err := loadConfig(viper.New(), flags)
+	require.NoError(t, err)
+
+	var errs validation.Errors
+	require.True(t, errors.As(c.Validate(), &errs))
+
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	require.Equal(t, []string{
+		"processor.destinationbucket",
+		"logger.formatter",
+		"reader.numpartitions",
+		"reader.brokers",
+		"writer.addr",
+		"writer.balancer",
+		"pipeline.maxbackoff",
+		"tracing.endpoint",
+		"tracing.sampleratio",
+	}, paths)
+}
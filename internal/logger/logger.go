@@ -1,10 +1,14 @@
 package logger
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/sirupsen/logrus"
 	logtest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/weak-head/data-pipe/internal/validation"
 )
 
 const (
@@ -15,6 +19,11 @@ const (
 	FieldError    = "error"
 )
 
+var (
+	// ErrUnknownFormatter happens when the log formatter is not supported.
+	ErrUnknownFormatter = errors.New("unknown log formatter")
+)
+
 // Config
 type Config struct {
 	// Log level: trace, debug, info, warning, error, fatal, panic
@@ -24,6 +33,21 @@ type Config struct {
 	Formatter string
 }
 
+// Validate returns the aggregated validation failures of the logger configuration.
+func (c Config) Validate() error {
+	var errs validation.Errors
+
+	if _, err := logrus.ParseLevel(c.Level); err != nil {
+		errs.Add("level", err)
+	}
+
+	if _, err := getFormatter(c.Formatter); err != nil {
+		errs.Add("formatter", err)
+	}
+
+	return errs.Err()
+}
+
 // Field
 type Field string
 
@@ -284,6 +308,6 @@ func getFormatter(formatter string) (logrus.Formatter, error) {
 		return &logrus.JSONFormatter{}, nil
 
 	default:
-		return &logrus.JSONFormatter{}, nil
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormatter, formatter)
 	}
 }
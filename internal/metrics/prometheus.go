@@ -2,82 +2,89 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/weak-head/data-pipe/internal/validation"
 )
 
 var (
-	// TODO: comments
-	dataFrameDuration = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:       "dataframe_durations_seconds",
-			Help:       "Data frame processing duration distributions.",
-			Objectives: map[float64]float64{},
-		},
-		[]string{"engine", "processing_kind"},
-	)
-
-	// TODO: comments
-	dataFrameDurationsHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "dataframe_durations_histogram_seconds",
-			Help: "Data frame processing duration distributions.",
-			// Buckets: prometheus.LinearBuckets(normMean-5*normDomain, .5*normDomain, 20),
-		},
-		[]string{"engine", "processing_kind"},
-	)
-
-	// TODO: comments
-	processingDuration = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:       "processing_durations_seconds",
-			Help:       "Processing duration distributions.",
-			Objectives: map[float64]float64{},
-		},
-		[]string{"engine", "processing_kind"},
-	)
-
-	// TODO: comments
-	processingDurationsHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "processing_durations_histogram_seconds",
-			Help: "Processing duration distributions.",
-			// Buckets: prometheus.LinearBuckets(normMean-5*normDomain, .5*normDomain, 20),
-		},
-		[]string{"engine", "processing_kind"},
-	)
-
-	// TODO: comments
-	dataFramesTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "dataframes_total",
-			Help: "Number of processed data frames.",
-		},
-		[]string{"engine", "processing_kind"},
-	)
-
-	// TODO: comments
-	pipelineFailures = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "pipeline_errors_total",
-			Help: "Number of pipeline errors.",
-		},
-		[]string{"engine", "failure"},
-	)
+	// ErrInvalidPath happens when the metrics path is not absolute.
+	ErrInvalidPath = errors.New("path should start with '/'")
+
+	// ErrInvalidName happens when the namespace or subsystem
+	// could not be used as a part of the metric name.
+	ErrInvalidName = errors.New("should have only letters, digits and underscores, and not start with a digit")
+
+	metricName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+var (
+	// durationBuckets are the buckets of the durations in seconds, from 1ms to ~33s.
+	durationBuckets = prometheus.ExponentialBuckets(0.001, 2, 16)
+
+	// sizeBuckets are the buckets of the sizes in bytes, from 1KiB to 256MiB.
+	sizeBuckets = prometheus.ExponentialBuckets(1024, 4, 10)
 )
 
 type Config struct {
 	Addr string
 	Path string
+
+	// Namespace and Subsystem prefix the metric names,
+	// e.g. "<namespace>_<subsystem>_dataframes_total".
+	// The prefixes are omitted if not set.
+	Namespace string
+	Subsystem string
 }
 
+// Validate returns the aggregated validation failures of the metrics configuration.
+func (c Config) Validate() error {
+	var errs validation.Errors
+	errs.Add("addr", validation.Addr(c.Addr))
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		errs.Add("path", ErrInvalidPath)
+	}
+	if c.Namespace != "" && !metricName.MatchString(c.Namespace) {
+		errs.Add("namespace", ErrInvalidName)
+	}
+	if c.Subsystem != "" && !metricName.MatchString(c.Subsystem) {
+		errs.Add("subsystem", ErrInvalidName)
+	}
+	return errs.Err()
+}
+
+// ServiceInfo defines the constant labels of the reported metrics,
+// the labels that are not set are empty.
 type ServiceInfo struct {
+	// Name is the "service" label.
+	Name string
+
+	// Instance is the "instance" label.
+	Instance string
+
+	// Engine is the "engine" label.
 	Engine string
 }
 
+// labels returns the constant labels of the service.
+// The empty labels are kept, so the metrics of the reporters
+// with the different service info have the same label names.
+func (i ServiceInfo) labels() prometheus.Labels {
+	return prometheus.Labels{
+		"service":  i.Name,
+		"instance": i.Instance,
+		"engine":   i.Engine,
+	}
+}
+
 // prometheusServer
 type prometheusServer struct {
 	server   *http.Server
@@ -85,33 +92,37 @@ type prometheusServer struct {
 	conf     Config
 }
 
-// NewPrometheusServer
-func NewPrometheusServer(conf Config) (*prometheusServer, error) {
+// NewPrometheusServer creates a new prometheus server with its own registry,
+// that serves the metrics of the given collectors, e.g. the reporters,
+// and the build info. The reporters of the server should have
+// the distinct service info.
+func NewPrometheusServer(conf Config, cs ...prometheus.Collector) (*prometheusServer, error) {
 	p := &prometheusServer{
 		registry: prometheus.NewRegistry(),
 		conf:     conf,
 	}
 
-	for _, c := range []prometheus.Collector{
-		dataFrameDuration,
-		dataFrameDurationsHistogram,
-		processingDuration,
-		processingDurationsHistogram,
-		dataFramesTotal,
-		pipelineFailures,
-		collectors.NewBuildInfoCollector(),
-	} {
+	cs = append(cs, collectors.NewBuildInfoCollector())
+	for _, c := range cs {
 		if err := p.registry.Register(c); err != nil {
 			return nil, err
 		}
 	}
 
+	path := p.conf.Path
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.HandlerFor(
+		p.registry,
+		promhttp.HandlerOpts{EnableOpenMetrics: true},
+	))
+
 	p.server = &http.Server{
-		Addr: p.conf.Addr,
-		Handler: promhttp.HandlerFor(
-			p.registry,
-			promhttp.HandlerOpts{EnableOpenMetrics: true},
-		),
+		Addr:    p.conf.Addr,
+		Handler: mux,
 	}
 
 	return p, nil
@@ -130,30 +141,336 @@ func (p *prometheusServer) Stop(ctx context.Context) error {
 	return p.server.Shutdown(ctx)
 }
 
-// reporter
+// reporter reports the pipeline metrics to its own collectors,
+// that are named with the configured namespace and subsystem,
+// and labeled with the service info.
+//
+// The reporter is a prometheus.Collector of its metrics,
+// that should be registered, e.g. by the prometheus server.
 type reporter struct {
-	info ServiceInfo
+	// dataFrameDuration is the summary of the data frame handling durations.
+	dataFrameDuration *prometheus.SummaryVec
+
+	// dataFrameDurationsHistogram is the histogram of the data frame handling durations.
+	dataFrameDurationsHistogram *prometheus.HistogramVec
+
+	// processingDuration is the summary of the pipeline stage durations.
+	processingDuration *prometheus.SummaryVec
+
+	// processingDurationsHistogram is the histogram of the pipeline stage durations.
+	processingDurationsHistogram *prometheus.HistogramVec
+
+	// dataFramesTotal is the number of the handled data frames.
+	dataFramesTotal *prometheus.CounterVec
+
+	// pipelineFailures is the number of the failed attempts.
+	pipelineFailures *prometheus.CounterVec
+
+	// retriesTotal is the number of the retried attempts of the pipeline stages.
+	retriesTotal *prometheus.CounterVec
+
+	// bytesTotal is the number of the fetched and written message bytes.
+	bytesTotal *prometheus.CounterVec
+
+	// dataFrameSize is the histogram of the data frame sizes.
+	dataFrameSize prometheus.Histogram
+
+	// convertedBlobSize is the histogram of the converted blob sizes.
+	convertedBlobSize prometheus.Histogram
+
+	// deadLettersTotal is the number of the messages sent to the dead letter writer.
+	deadLettersTotal *prometheus.CounterVec
+
+	// dataFramesInFlight is the number of the data frames that are being handled.
+	dataFramesInFlight prometheus.Gauge
+
+	// pipelinesRunning is the number of the running pipelines.
+	pipelinesRunning prometheus.Gauge
+
+	// consumerLag is the number of the messages of the partition,
+	// that are not read by the consumer yet.
+	consumerLag *prometheus.GaugeVec
+
+	// converterStepDuration is the histogram of the converter chain step durations.
+	converterStepDuration *prometheus.HistogramVec
+
+	// converterStepFailures is the number of the failed converter chain steps.
+	converterStepFailures *prometheus.CounterVec
+
+	collectors []prometheus.Collector
+}
+
+// NewReporter creates a new reporter with its own metrics,
+// that are named with the namespace and subsystem of the configuration,
+// and labeled with the service info.
+func NewReporter(conf Config, info ServiceInfo) (*reporter, error) {
+	labels := info.labels()
+
+	r := &reporter{
+		dataFrameDuration: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "dataframe_durations_seconds",
+				Help:        "Data frame processing duration distributions.",
+				Objectives:  map[float64]float64{},
+				ConstLabels: labels,
+			},
+			[]string{"processing_kind"},
+		),
+		dataFrameDurationsHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "dataframe_durations_histogram_seconds",
+				Help:        "Data frame processing duration distributions.",
+				Buckets:     durationBuckets,
+				ConstLabels: labels,
+			},
+			[]string{"processing_kind"},
+		),
+		processingDuration: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "processing_durations_seconds",
+				Help:        "Processing duration distributions.",
+				Objectives:  map[float64]float64{},
+				ConstLabels: labels,
+			},
+			[]string{"processing_kind"},
+		),
+		processingDurationsHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "processing_durations_histogram_seconds",
+				Help:        "Processing duration distributions.",
+				Buckets:     durationBuckets,
+				ConstLabels: labels,
+			},
+			[]string{"processing_kind"},
+		),
+		dataFramesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "dataframes_total",
+				Help:        "Number of processed data frames.",
+				ConstLabels: labels,
+			},
+			[]string{"processing_kind"},
+		),
+		pipelineFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "pipeline_errors_total",
+				Help:        "Number of pipeline errors.",
+				ConstLabels: labels,
+			},
+			[]string{"failure"},
+		),
+		retriesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "retries_total",
+				Help:        "Number of retries of the failed pipeline stages.",
+				ConstLabels: labels,
+			},
+			[]string{"stage"},
+		),
+		bytesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "message_bytes_total",
+				Help:        "Number of fetched and written message bytes.",
+				ConstLabels: labels,
+			},
+			[]string{"stage"},
+		),
+		dataFrameSize: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "dataframe_size_bytes",
+				Help:        "Data frame size distributions.",
+				Buckets:     sizeBuckets,
+				ConstLabels: labels,
+			},
+		),
+		convertedBlobSize: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "converted_blob_size_bytes",
+				Help:        "Converted blob size distributions.",
+				Buckets:     sizeBuckets,
+				ConstLabels: labels,
+			},
+		),
+		deadLettersTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "deadletters_total",
+				Help:        "Number of messages sent to the dead letter stream.",
+				ConstLabels: labels,
+			},
+			[]string{"stage"},
+		),
+		dataFramesInFlight: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "dataframes_in_flight",
+				Help:        "Number of data frames that are being processed.",
+				ConstLabels: labels,
+			},
+		),
+		pipelinesRunning: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "pipelines_running",
+				Help:        "Number of running pipelines.",
+				ConstLabels: labels,
+			},
+		),
+		consumerLag: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "consumer_lag_messages",
+				Help:        "Number of messages in the partition that are not fetched yet.",
+				ConstLabels: labels,
+			},
+			[]string{"topic", "partition"},
+		),
+		converterStepDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "converter_step_durations_seconds",
+				Help:        "Converter chain step duration distributions.",
+				Buckets:     durationBuckets,
+				ConstLabels: labels,
+			},
+			[]string{"step", "converter"},
+		),
+		converterStepFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   conf.Namespace,
+				Subsystem:   conf.Subsystem,
+				Name:        "converter_step_errors_total",
+				Help:        "Number of failed converter chain steps.",
+				ConstLabels: labels,
+			},
+			[]string{"step", "converter"},
+		),
+	}
+
+	r.collectors = []prometheus.Collector{
+		r.dataFrameDuration,
+		r.dataFrameDurationsHistogram,
+		r.processingDuration,
+		r.processingDurationsHistogram,
+		r.dataFramesTotal,
+		r.pipelineFailures,
+		r.retriesTotal,
+		r.bytesTotal,
+		r.dataFrameSize,
+		r.convertedBlobSize,
+		r.deadLettersTotal,
+		r.dataFramesInFlight,
+		r.pipelinesRunning,
+		r.consumerLag,
+		r.converterStepDuration,
+		r.converterStepFailures,
+	}
+
+	return r, nil
+}
+
+// Describe sends the descriptors of the reporter metrics.
+func (r *reporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range r.collectors {
+		c.Describe(ch)
+	}
 }
 
-// NewReporter
-func NewReporter(info ServiceInfo) (*reporter, error) {
-	return &reporter{info: info}, nil
+// Collect sends the reporter metrics.
+func (r *reporter) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range r.collectors {
+		c.Collect(ch)
+	}
 }
 
 // DataFrameProcessed
-func (r *reporter) DataFrameProcessed(processingKind string, milliseconds float64) {
-	dataFrameDuration.WithLabelValues(r.info.Engine, processingKind).Observe(milliseconds)
-	dataFrameDurationsHistogram.WithLabelValues(r.info.Engine, processingKind).Observe(milliseconds)
-	dataFramesTotal.WithLabelValues(r.info.Engine, processingKind).Inc()
+func (r *reporter) DataFrameProcessed(processingKind string, duration time.Duration) {
+	r.dataFrameDuration.WithLabelValues(processingKind).Observe(duration.Seconds())
+	r.dataFrameDurationsHistogram.WithLabelValues(processingKind).Observe(duration.Seconds())
+	r.dataFramesTotal.WithLabelValues(processingKind).Inc()
 }
 
 // ProcessingFinished
-func (r *reporter) ProcessingFinished(processingKind string, milliseconds float64) {
-	processingDuration.WithLabelValues(r.info.Engine, processingKind).Observe(milliseconds)
-	processingDurationsHistogram.WithLabelValues(r.info.Engine, processingKind).Observe(milliseconds)
+func (r *reporter) ProcessingFinished(processingKind string, duration time.Duration) {
+	r.processingDuration.WithLabelValues(processingKind).Observe(duration.Seconds())
+	r.processingDurationsHistogram.WithLabelValues(processingKind).Observe(duration.Seconds())
 }
 
 // PipelineFailed
 func (r *reporter) PipelineFailed(failure string) {
-	pipelineFailures.WithLabelValues(r.info.Engine, failure).Inc()
+	r.pipelineFailures.WithLabelValues(failure).Inc()
+}
+
+// RetryAttempted counts the retry of the failed stage.
+func (r *reporter) RetryAttempted(stage string) {
+	r.retriesTotal.WithLabelValues(stage).Inc()
+}
+
+// BytesTransferred counts the fetched or written message bytes.
+func (r *reporter) BytesTransferred(stage string, bytes int) {
+	r.bytesTotal.WithLabelValues(stage).Add(float64(bytes))
+}
+
+// DataFrameConverted observes the data frame size, if it is known,
+// and the converted blob size.
+func (r *reporter) DataFrameConverted(frameBytes int64, blobBytes int64) {
+	if frameBytes > 0 {
+		r.dataFrameSize.Observe(float64(frameBytes))
+	}
+	r.convertedBlobSize.Observe(float64(blobBytes))
+}
+
+// DeadLettered counts the message sent to the dead letter stream.
+func (r *reporter) DeadLettered(stage string) {
+	r.deadLettersTotal.WithLabelValues(stage).Inc()
+}
+
+// DataFramesInFlight changes the number of the data frames that are being processed.
+func (r *reporter) DataFramesInFlight(delta int) {
+	r.dataFramesInFlight.Add(float64(delta))
+}
+
+// PipelinesRunning changes the number of the running pipelines.
+func (r *reporter) PipelinesRunning(delta int) {
+	r.pipelinesRunning.Add(float64(delta))
+}
+
+// ConsumerLag sets the lag of the topic partition.
+func (r *reporter) ConsumerLag(topic string, partition string, lag int64) {
+	r.consumerLag.WithLabelValues(topic, partition).Set(float64(lag))
+}
+
+// ConverterStepFinished observes the duration of the converter chain step.
+func (r *reporter) ConverterStepFinished(step string, converter string, duration time.Duration) {
+	r.converterStepDuration.WithLabelValues(step, converter).Observe(duration.Seconds())
+}
+
+// ConverterStepFailed counts the failure of the converter chain step.
+func (r *reporter) ConverterStepFailed(step string, converter string) {
+	r.converterStepFailures.WithLabelValues(step, converter).Inc()
 }
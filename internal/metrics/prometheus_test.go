@@ -1 +1,228 @@
 package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/validation"
+)
+
+func TestPrometheus(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"servers have their own registries":        testServersHaveOwnRegistries,
+		"server collects reporters with labels":    testServerCollectsReporters,
+		"server fails on duplicate reporters":      testServerFailsOnDuplicateReporters,
+		"server collects reporters without labels": testServerCollectsReportersWithoutLabels,
+		"names and labels the reported metrics":    testNamesAndLabelsMetrics,
+		"observes the durations in seconds":        testObservesSeconds,
+		"reports the consumer lag by partition":    testReportsConsumerLag,
+		"counts the transferred message bytes":     testCountsTransferredBytes,
+		"observes the frame and blob sizes":        testObservesSizes,
+		"tracks the frames and pipelines running":  testTracksInFlight,
+		"counts the retries and dead letters":      testCountsRetriesAndDeadLetters,
+		"reports the converter chain steps":        testReportsConverterSteps,
+		"validates the metrics configuration":      testValidatesConfig,
+		"keeps the service labels that are empty":  testKeepsEmptyLabels,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func newReporter(t *testing.T, conf Config, info ServiceInfo) *reporter {
+	r, err := NewReporter(conf, info)
+	require.NoError(t, err)
+	return r
+}
+
+func testServersHaveOwnRegistries(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		_, err := NewPrometheusServer(Config{Addr: ":9090"}, newReporter(t, Config{}, ServiceInfo{Engine: "gzip"}))
+		require.NoError(t, err)
+	}
+}
+
+func testServerCollectsReporters(t *testing.T) {
+	gzip := newReporter(t, Config{}, ServiceInfo{Engine: "gzip"})
+	identity := newReporter(t, Config{}, ServiceInfo{Engine: "identity"})
+
+	server, err := NewPrometheusServer(Config{Addr: ":9090"}, gzip, identity)
+	require.NoError(t, err)
+
+	gzip.DataFrameProcessed("converted", time.Second)
+	identity.DataFrameProcessed("converted", time.Second)
+	identity.DataFrameProcessed("rejected", time.Second)
+
+	count, err := testutil.GatherAndCount(server.registry, "dataframes_total")
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(gzip.dataFramesTotal.WithLabelValues("converted")))
+	require.Equal(t, 1.0, testutil.ToFloat64(identity.dataFramesTotal.WithLabelValues("rejected")))
+}
+
+func testServerFailsOnDuplicateReporters(t *testing.T) {
+	_, err := NewPrometheusServer(
+		Config{Addr: ":9090"},
+		newReporter(t, Config{}, ServiceInfo{Engine: "gzip"}),
+		newReporter(t, Config{}, ServiceInfo{Engine: "gzip"}),
+	)
+	require.Error(t, err)
+}
+
+func testServerCollectsReportersWithoutLabels(t *testing.T) {
+	named := newReporter(t, Config{}, ServiceInfo{Name: "data-pipe"})
+	gzip := newReporter(t, Config{}, ServiceInfo{Engine: "gzip"})
+
+	server, err := NewPrometheusServer(Config{Addr: ":9090"}, named, gzip)
+	require.NoError(t, err)
+
+	named.PipelinesRunning(1)
+	gzip.PipelinesRunning(2)
+
+	expected := `
+# HELP pipelines_running Number of running pipelines.
+# TYPE pipelines_running gauge
+pipelines_running{engine="",instance="",service="data-pipe"} 1
+pipelines_running{engine="gzip",instance="",service=""} 2
+`
+	require.NoError(t, testutil.GatherAndCompare(server.registry, strings.NewReader(expected), "pipelines_running"))
+}
+
+func testNamesAndLabelsMetrics(t *testing.T) {
+	r := newReporter(t,
+		Config{Namespace: "dp", Subsystem: "pipeline"},
+		ServiceInfo{Name: "data-pipe", Instance: "i1", Engine: "gzip"},
+	)
+	r.PipelineFailed("fetch")
+
+	expected := `
+# HELP dp_pipeline_pipeline_errors_total Number of pipeline errors.
+# TYPE dp_pipeline_pipeline_errors_total counter
+dp_pipeline_pipeline_errors_total{engine="gzip",failure="fetch",instance="i1",service="data-pipe"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(r, strings.NewReader(expected), "dp_pipeline_pipeline_errors_total"))
+}
+
+func testObservesSeconds(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{Engine: "gzip"})
+	r.ProcessingFinished("write", 1500*time.Millisecond)
+
+	expected := `
+# HELP processing_durations_seconds Processing duration distributions.
+# TYPE processing_durations_seconds summary
+processing_durations_seconds_sum{engine="gzip",instance="",processing_kind="write",service=""} 1.5
+processing_durations_seconds_count{engine="gzip",instance="",processing_kind="write",service=""} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(r, strings.NewReader(expected), "processing_durations_seconds"))
+}
+
+func testReportsConsumerLag(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{})
+	r.ConsumerLag("frames", "0", 10)
+	r.ConsumerLag("frames", "1", 3)
+	r.ConsumerLag("frames", "0", 7)
+
+	require.Equal(t, 7.0, testutil.ToFloat64(r.consumerLag.WithLabelValues("frames", "0")))
+	require.Equal(t, 3.0, testutil.ToFloat64(r.consumerLag.WithLabelValues("frames", "1")))
+}
+
+func testCountsTransferredBytes(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{})
+	r.BytesTransferred("fetch", 10)
+	r.BytesTransferred("write", 5)
+	r.BytesTransferred("fetch", 3)
+
+	require.Equal(t, 13.0, testutil.ToFloat64(r.bytesTotal.WithLabelValues("fetch")))
+	require.Equal(t, 5.0, testutil.ToFloat64(r.bytesTotal.WithLabelValues("write")))
+}
+
+// histogram returns the sample count and sum of the histogram.
+func histogram(t *testing.T, h prometheus.Histogram) (uint64, float64) {
+	var m dto.Metric
+	require.NoError(t, h.Write(&m))
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func testObservesSizes(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{})
+	r.DataFrameConverted(0, 100)
+	r.DataFrameConverted(2048, 512)
+
+	// The size of the data frame is observed only if it is known.
+	count, sum := histogram(t, r.dataFrameSize)
+	require.Equal(t, uint64(1), count)
+	require.Equal(t, 2048.0, sum)
+
+	count, sum = histogram(t, r.convertedBlobSize)
+	require.Equal(t, uint64(2), count)
+	require.Equal(t, 612.0, sum)
+}
+
+func testTracksInFlight(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{})
+	r.DataFramesInFlight(1)
+	r.DataFramesInFlight(1)
+	r.DataFramesInFlight(-1)
+	r.PipelinesRunning(1)
+	r.PipelinesRunning(1)
+	r.PipelinesRunning(-1)
+	r.PipelinesRunning(-1)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(r.dataFramesInFlight))
+	require.Equal(t, 0.0, testutil.ToFloat64(r.pipelinesRunning))
+}
+
+func testCountsRetriesAndDeadLetters(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{})
+	r.RetryAttempted("write")
+	r.RetryAttempted("write")
+	r.DeadLettered("unmarshal")
+
+	require.Equal(t, 2.0, testutil.ToFloat64(r.retriesTotal.WithLabelValues("write")))
+	require.Equal(t, 1.0, testutil.ToFloat64(r.deadLettersTotal.WithLabelValues("unmarshal")))
+}
+
+func testReportsConverterSteps(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{})
+	r.ConverterStepFinished("0", "gunzip", 500*time.Millisecond)
+	r.ConverterStepFinished("0", "gunzip", 250*time.Millisecond)
+	r.ConverterStepFailed("1", "gzip")
+
+	count, sum := histogram(t, r.converterStepDuration.WithLabelValues("0", "gunzip").(prometheus.Histogram))
+	require.Equal(t, uint64(2), count)
+	require.Equal(t, 0.75, sum)
+
+	require.Equal(t, 0.0, testutil.ToFloat64(r.converterStepFailures.WithLabelValues("0", "gunzip")))
+	require.Equal(t, 1.0, testutil.ToFloat64(r.converterStepFailures.WithLabelValues("1", "gzip")))
+}
+
+func testValidatesConfig(t *testing.T) {
+	require.NoError(t, Config{Addr: ":9090", Path: "/metrics", Namespace: "dp", Subsystem: "pipeline_1"}.Validate())
+
+	var errs validation.Errors
+	require.True(t, errors.As(Config{Addr: ":9090", Namespace: "data-pipe", Subsystem: "1st"}.Validate(), &errs))
+	require.Len(t, errs, 2)
+	require.Equal(t, "namespace", errs[0].Path)
+	require.True(t, errors.Is(errs[0].Err, ErrInvalidName))
+	require.Equal(t, "subsystem", errs[1].Path)
+	require.True(t, errors.Is(errs[1].Err, ErrInvalidName))
+}
+
+func testKeepsEmptyLabels(t *testing.T) {
+	r := newReporter(t, Config{}, ServiceInfo{Name: "data-pipe"})
+	r.PipelinesRunning(1)
+
+	expected := `
+# HELP pipelines_running Number of running pipelines.
+# TYPE pipelines_running gauge
+pipelines_running{engine="",instance="",service="data-pipe"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(r, strings.NewReader(expected), "pipelines_running"))
+}
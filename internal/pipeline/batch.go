@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+
+	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/weak-head/data-pipe/internal/tracing"
+)
+
+// batch accumulates the handled messages,
+// so they are written and committed together.
+type batch struct {
+	// tasks are the handled input messages.
+	tasks []*task
+
+	// outputs are the messages that should be written to the writer.
+	outputs []kafka.Message
+}
+
+// add adds the handled message and its output to the batch.
+// The output is nil if the message has been rejected.
+func (b *batch) add(t *task, output *kafka.Message) {
+	b.tasks = append(b.tasks, t)
+	if output != nil {
+		b.outputs = append(b.outputs, *output)
+	}
+}
+
+// size returns the number of the handled messages in the batch.
+func (b *batch) size() int {
+	return len(b.tasks)
+}
+
+// empty reports whether the batch has no handled messages.
+func (b *batch) empty() bool {
+	return len(b.tasks) == 0
+}
+
+// reset removes all messages from the batch.
+func (b *batch) reset() {
+	b.tasks = nil
+	b.outputs = nil
+}
+
+// links returns the links to the spans of the handled messages.
+func (b *batch) links() []trace.Link {
+	links := make([]trace.Link, 0, len(b.tasks))
+	for _, t := range b.tasks {
+		if t.span.IsValid() {
+			links = append(links, trace.Link{SpanContext: t.span})
+		}
+	}
+	return links
+}
+
+// flush writes the batch outputs to the writer with a single write,
+// and then commits the highest contiguous completed offset
+// of each partition with a single commit.
+// The write and commit spans are linked to the spans of the handled messages.
+func (p *Pipeline) flush(ctx context.Context, tracker *offsetTracker, b *batch) error {
+	links := b.links()
+
+	if len(b.outputs) > 0 {
+		writeCtx, span := p.startBatchSpan(ctx, "pipeline.write", len(b.outputs), links)
+		err := p.write(writeCtx, p.writer, StageWrite, b.outputs...)
+		tracing.End(span, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	committable := make(map[topicPartition]kafka.Message)
+	for _, t := range b.tasks {
+		if m, ok := tracker.complete(t); ok {
+			committable[topicPartition{m.Topic, m.Partition}] = m
+		}
+	}
+
+	if len(committable) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafka.Message, 0, len(committable))
+	for _, m := range committable {
+		msgs = append(msgs, m)
+	}
+
+	commitCtx, span := p.startBatchSpan(ctx, "pipeline.commit", len(msgs), links)
+	err := p.commit(commitCtx, msgs...)
+	tracing.End(span, err)
+	return err
+}
+
+// startBatchSpan starts the span of the batch stage.
+func (p *Pipeline) startBatchSpan(ctx context.Context, name string, size int, links []trace.Link) (context.Context, trace.Span) {
+	return p.tracer.Start(ctx, name,
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("pipeline.id", p.id),
+			attribute.Int("pipeline.batch_size", size),
+		),
+	)
+}
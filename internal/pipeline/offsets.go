@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"sync"
+
+	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// task is a message that is handled by the pipeline.
+type task struct {
+	msg kafka.Message
+
+	// completed is set when the message is handled
+	// and could be committed.
+	completed bool
+
+	// span is the span of the message handling,
+	// that is linked to the spans of the batch write and commit.
+	span trace.SpanContext
+}
+
+// topicPartition uniquely identifies a partition of a topic.
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// offsetTracker tracks the in-flight messages of each partition,
+// so the messages that are handled out of order
+// are committed in order of their offsets.
+//
+// The messages of a partition are tracked in the order they are fetched,
+// and only the highest contiguous completed message is committed.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition][]*task
+}
+
+// newOffsetTracker creates a new offset tracker.
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[topicPartition][]*task),
+	}
+}
+
+// track starts tracking the fetched message.
+// The messages of the same partition should be tracked in order of their offsets.
+func (o *offsetTracker) track(m kafka.Message) *task {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	t := &task{msg: m}
+	key := topicPartition{m.Topic, m.Partition}
+	o.partitions[key] = append(o.partitions[key], t)
+	return t
+}
+
+// complete marks the task as completed, and returns the message
+// with the highest contiguous completed offset of the task partition.
+// It returns false if there is nothing to commit, because some preceding
+// message of the partition is still in-flight.
+func (o *offsetTracker) complete(t *task) (kafka.Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	t.completed = true
+
+	key := topicPartition{t.msg.Topic, t.msg.Partition}
+	queue := o.partitions[key]
+
+	var (
+		last      kafka.Message
+		committed bool
+	)
+	for len(queue) > 0 && queue[0].completed {
+		last, committed = queue[0].msg, true
+		queue = queue[1:]
+	}
+
+	if len(queue) == 0 {
+		delete(o.partitions, key)
+	} else {
+		o.partitions[key] = queue
+	}
+
+	return last, committed
+}
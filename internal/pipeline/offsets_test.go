@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"testing"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := newOffsetTracker()
+
+	p0 := []*task{
+		tracker.track(kafka.Message{Topic: "frames", Partition: 0, Offset: 10}),
+		tracker.track(kafka.Message{Topic: "frames", Partition: 0, Offset: 11}),
+		tracker.track(kafka.Message{Topic: "frames", Partition: 0, Offset: 12}),
+	}
+	p1 := tracker.track(kafka.Message{Topic: "frames", Partition: 1, Offset: 5})
+
+	// The preceding message of the partition is in-flight.
+	_, ok := tracker.complete(p0[1])
+	require.False(t, ok)
+
+	// Partitions are tracked independently.
+	m, ok := tracker.complete(p1)
+	require.True(t, ok)
+	require.Equal(t, int64(5), m.Offset)
+
+	// The highest contiguous completed message is committed.
+	m, ok = tracker.complete(p0[0])
+	require.True(t, ok)
+	require.Equal(t, int64(11), m.Offset)
+
+	m, ok = tracker.complete(p0[2])
+	require.True(t, ok)
+	require.Equal(t, int64(12), m.Offset)
+
+	require.Empty(t, tracker.partitions)
+}
@@ -3,14 +3,22 @@ package pipeline
 import (
 	"context"
 	"errors"
-	"fmt"
-	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/rs/xid"
 	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 
 	api "github.com/weak-head/data-pipe/api/v1"
 	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/tracing"
+	"github.com/weak-head/data-pipe/pkg/failure"
 )
 
 const (
@@ -26,9 +34,94 @@ const (
 	// to commit a message to the reader before giving up.
 	retryCommitCount = 3
 
+	// retryProcessCount defines the number of attempts
+	// to process a data frame, that has failed with a transient
+	// or unclassified failure, before giving up.
+	retryProcessCount = 3
+
 	// maxPipelines defines the maximum number of pipelines that
 	// could be started in a single instance of the service
 	maxPipelines = 10000
+
+	// defaultBatchTimeout defines the maximum time a handled message
+	// waits in the batch, if the batch timeout is not configured.
+	defaultBatchTimeout = 100 * time.Millisecond
+
+	// instrumentationName is the name of the pipeline tracer.
+	instrumentationName = "github.com/weak-head/data-pipe/internal/pipeline"
+)
+
+const (
+	// HeaderFailureStage is the dead letter header
+	// with the pipeline stage that has failed.
+	HeaderFailureStage = "dp-failure-stage"
+
+	// HeaderFailureError is the dead letter header
+	// with the error text of the failure.
+	HeaderFailureError = "dp-failure-error"
+
+	// HeaderFailureAttempts is the dead letter header
+	// with the number of attempts to process the message.
+	HeaderFailureAttempts = "dp-failure-attempts"
+
+	// HeaderFailureTime is the dead letter header
+	// with the RFC 3339 timestamp of the failure.
+	HeaderFailureTime = "dp-failure-time"
+
+	// HeaderPipelineID is the header with the id of the pipeline
+	// that has handled the message.
+	HeaderPipelineID = "dp-pipeline-id"
+
+	// HeaderSourceTopic, HeaderSourcePartition and HeaderSourceOffset
+	// are the headers that identify the original message.
+	HeaderSourceTopic     = "dp-source-topic"
+	HeaderSourcePartition = "dp-source-partition"
+	HeaderSourceOffset    = "dp-source-offset"
+
+	// HeaderConverterKind is the header with the kind
+	// of the converter that has converted the data frame.
+	HeaderConverterKind = "dp-converter-kind"
+
+	// HeaderProcessingDuration is the header with the duration
+	// of the data frame processing in milliseconds, including the retries.
+	HeaderProcessingDuration = "dp-processing-duration-ms"
+
+	// headerPrefix is the prefix of the headers that are added by the pipeline.
+	// The input message headers with the prefix are not propagated.
+	headerPrefix = "dp-"
+)
+
+const (
+	// StageUnmarshal is the failure of the input frame decoding.
+	StageUnmarshal = "unmarshal"
+
+	// StageProcess is the failure of the data frame processing.
+	StageProcess = "process"
+
+	// StageMarshal is the failure of the converted blob encoding.
+	StageMarshal = "marshal"
+
+	// StageFetch is the failure of the message fetching.
+	StageFetch = "fetch"
+
+	// StageWrite is the failure of the converted blob message writing.
+	StageWrite = "write"
+
+	// StageCommit is the failure of the message committing.
+	StageCommit = "commit"
+
+	// StageDeadLetter is the failure of the dead letter message writing.
+	StageDeadLetter = "deadletter"
+)
+
+const (
+	// ProcessingConverted is the processing kind of the data frames,
+	// that have been converted.
+	ProcessingConverted = "converted"
+
+	// ProcessingRejected is the processing kind of the data frames,
+	// that have been rejected and sent to the dead letter writer or dropped.
+	ProcessingRejected = "rejected"
 )
 
 var (
@@ -46,21 +139,13 @@ var (
 
 	// ErrNoReporterProvided happens when reporter is not provided.
 	ErrNoReporterProvided = errors.New("no reporter provided")
-
-	// Unique pipeline ids
-	uniqueIds chan string
 )
 
-// Generate unique IDs for the newly created pipelines
-func init() {
-	uniqueIds = make(chan string)
-	go func(numInts int, ch chan string) {
-		source := rand.NewSource(time.Now().UnixNano())
-		generator := rand.New(source)
-		for i := 0; i < numInts; i++ {
-			ch <- fmt.Sprintf("p_%d", generator.Intn(numInts*100))
-		}
-	}(maxPipelines, uniqueIds)
+// newPipelineID returns a new globally unique pipeline id,
+// so the restarted pipelines and the pipelines of other instances
+// of the service never share the id.
+func newPipelineID() string {
+	return "p_" + xid.New().String()
 }
 
 // Reader is a transactional message reader.
@@ -75,43 +160,161 @@ type Writer interface {
 }
 
 // Processor defines a data frame processor.
+//
+// The processing failures should be classified (see failure.Kind):
+//   - transient failures are retried, and the pipeline is stopped if retries are exhausted
+//   - permanent failures are sent to the dead letter writer
+//   - unclassified failures are retried, and then sent to the dead letter writer
+//   - failures caused by the canceled context stop the pipeline
 type Processor interface {
 	Process(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error)
 }
 
 // Sleeper is a routine sleeper with some sleeping strategy
 // and ability to reset the strategy state.
+// Sleep returns once the sleep is over or the context is done.
 type Sleeper interface {
-	Sleep()
+	Sleep(ctx context.Context)
 	Reset()
 }
 
+// SleeperFactory creates a new sleeper with its own strategy state.
+// Each attempt loop of the pipeline, e.g. the retries of a single write,
+// sleeps with its own sleeper, so the concurrent attempt loops
+// don't grow the sleep durations of each other.
+type SleeperFactory func() Sleeper
+
 // Reporter is a pipeline status and progress reporter that collects
 // and aggregates metrics related to pipeline flow.
+//
+// DataFrameProcessed is reported once the message is handled, with the processing
+// kind of the data frame (ProcessingConverted or ProcessingRejected) and the duration
+// of the handling.
+//
+// ProcessingFinished is reported once the pipeline stage is finished,
+// with the stage (StageFetch, StageProcess, StageWrite, StageCommit or StageDeadLetter)
+// as the processing kind, and the duration of the stage, including the retries.
+//
+// PipelineFailed is reported on each failed attempt, with the failed stage as the failure,
+// e.g. StageFetch. The processing failures are reported with the failure kind,
+// e.g. "process_transient" (see failure.Kind).
+//
+// RetryAttempted is reported each time the failed stage is retried.
+//
+// BytesTransferred is reported with the size of the fetched (StageFetch)
+// and written (StageWrite or StageDeadLetter) messages.
+//
+// DataFrameConverted is reported with the size of the data frame, that is zero
+// if the size is not known by the processor, and the size of the converted blob.
+//
+// DeadLettered is reported once the message is sent to the dead letter writer,
+// with the stage that has failed, e.g. StageUnmarshal.
+//
+// DataFramesInFlight and PipelinesRunning are reported with the change
+// of the number of the data frames that are being handled, and the number
+// of the running pipelines.
+//
+// ConsumerLag is reported once the message is fetched, with the number
+// of the messages in the partition that follow the fetched message.
 type Reporter interface {
-	DataFrameProcessed(processingKind string, milliseconds float64)
-	ConvertionFinished(processingKind string, milliseconds float64)
+	DataFrameProcessed(processingKind string, duration time.Duration)
+	ProcessingFinished(processingKind string, duration time.Duration)
 	PipelineFailed(failure string)
+	RetryAttempted(stage string)
+	BytesTransferred(stage string, bytes int)
+	DataFrameConverted(frameBytes int64, blobBytes int64)
+	DeadLettered(stage string)
+	DataFramesInFlight(delta int)
+	PipelinesRunning(delta int)
+	ConsumerLag(topic string, partition string, lag int64)
+}
+
+// Config defines the pipeline behaviour.
+type Config struct {
+	// Maximum number of messages that are handled concurrently.
+	// The messages are handled one at a time if not set.
+	Concurrency int
+
+	// Time given to the in-flight messages to be handled and committed,
+	// once the pipeline is stopped. The pipeline stops fetching new messages,
+	// but the in-flight messages are drained until the grace period is over.
+	// The in-flight messages are abandoned immediately if not set.
+	GracePeriod time.Duration
+
+	// Maximum number of handled messages that are written
+	// and committed together. The messages are written and committed
+	// one at a time if not set.
+	BatchSize int
+
+	// Maximum time a handled message waits in the batch,
+	// before the batch is written and committed.
+	// The default batch timeout is used if not set.
+	BatchTimeout time.Duration
+
+	// ConverterKind is the kind of the data frame converter,
+	// that is reported in the output message headers.
+	ConverterKind string `mapstructure:"-"`
+}
+
+// concurrency returns the number of concurrently handled messages.
+func (c Config) concurrency() int {
+	if c.Concurrency <= 0 {
+		return 1
+	}
+	return c.Concurrency
+}
+
+// batchSize returns the maximum number of messages in a batch.
+func (c Config) batchSize() int {
+	if c.BatchSize <= 0 {
+		return 1
+	}
+	return c.BatchSize
+}
+
+// batchTimeout returns the maximum time a message waits in the batch.
+func (c Config) batchTimeout() time.Duration {
+	if c.BatchTimeout <= 0 {
+		return defaultBatchTimeout
+	}
+	return c.BatchTimeout
 }
 
 // Pipeline is a document processing pipeline.
 type Pipeline struct {
-	processor Processor
-	reader    Reader
-	writer    Writer
+	id     string
+	config Config
 
-	sleeper  Sleeper
-	reporter Reporter
+	processor  Processor
+	reader     Reader
+	writer     Writer
+	deadLetter Writer
 
-	log logger.Log
+	newSleeper SleeperFactory
+	reporter   Reporter
+
+	tracer trace.Tracer
+	log    logger.Log
 }
 
 // NewPipeline creates and initializes a new document processing pipeline.
+//
+// The dead letter writer is optional. If it is provided, the messages
+// that the pipeline fails to handle are sent to the dead letter writer,
+// otherwise such messages are dropped.
+//
+// If the pipeline handles messages concurrently, the writers, the processor
+// and the reporter should be safe for concurrent use.
+//
+// The pipeline spans are started with the global tracer provider (see otel.SetTracerProvider),
+// that should be set before the pipeline is created.
 func NewPipeline(
+	config Config,
 	reader Reader,
 	writer Writer,
+	deadLetter Writer,
 	processor Processor,
-	sleeper Sleeper,
+	newSleeper SleeperFactory,
 	reporter Reporter,
 	log logger.Log,
 ) (*Pipeline, error) {
@@ -127,7 +330,7 @@ func NewPipeline(
 		return nil, ErrNoProcessorProvided
 	}
 
-	if sleeper == nil {
+	if newSleeper == nil {
 		return nil, ErrNoSleeperProvided
 	}
 
@@ -135,19 +338,29 @@ func NewPipeline(
 		return nil, ErrNoReporterProvided
 	}
 
+	id := newPipelineID()
 	return &Pipeline{
-		processor: processor,
-		reader:    reader,
-		writer:    writer,
-		sleeper:   sleeper,
-		reporter:  reporter,
+		id:         id,
+		config:     config,
+		processor:  processor,
+		reader:     reader,
+		writer:     writer,
+		deadLetter: deadLetter,
+		newSleeper: newSleeper,
+		reporter:   reporter,
+		tracer:     otel.Tracer(instrumentationName),
 		log: log.WithFields(logger.Fields{
 			logger.FieldPackage: "pipeline",
-			"pipeline_id":       <-uniqueIds,
+			"pipeline_id":       id,
 		}),
 	}, nil
 }
 
+// ID returns the unique id of the pipeline.
+func (p *Pipeline) ID() string {
+	return p.id
+}
+
 // Run starts the document processing pipeline,
 // that ensures that each document is processed at least once.
 //
@@ -156,24 +369,269 @@ func NewPipeline(
 // and form extraction. The extracted information is saved back to the storage
 // and the processed document metadata is send down the data pipeline
 // to the specified kafka stream.
+//
+// Up to Config.Concurrency messages are handled concurrently.
+// The messages could be handled out of order, but they are committed
+// in order of their offsets within each partition.
+//
+// The handled messages are accumulated in batches of up to Config.BatchSize
+// messages, or for up to Config.BatchTimeout. Each batch is written
+// to the writer and then committed to the reader at once.
+//
+// Once the context is canceled, the pipeline stops fetching new messages,
+// and the in-flight messages are drained within the Config.GracePeriod.
+// The messages that are not flushed within the grace period are left uncommitted,
+// and the pipeline stops without an error.
 func (p *Pipeline) Run(ctx context.Context) error {
 	log := p.log.WithField(logger.FieldFunction, "Pipeline.Run")
 	log.Info("Starting the pipeline.")
 
+	p.reporter.PipelinesRunning(1)
+	defer p.reporter.PipelinesRunning(-1)
+
+	// The fetching is stopped as soon as the pipeline is stopped.
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The in-flight messages are handled with a separate context,
+	// that outlives the pipeline context for the grace period.
+	workCtx, workCancel := p.drainContext(ctx)
+	defer workCancel()
+
+	stop := func() {
+		cancel()
+		workCancel()
+	}
+
+	var (
+		concurrency = p.config.concurrency()
+		batchSize   = p.config.batchSize()
+		tracker     = newOffsetTracker()
+
+		// slots limits the number of in-flight messages.
+		// A slot is taken before a message is fetched,
+		// and released once the batch with the message is committed.
+		slots = make(chan struct{}, concurrency+batchSize-1)
+
+		tasks    = make(chan *task)
+		outcomes = make(chan outcome, concurrency)
+		fetchErr = make(chan error, 1)
+		wg       sync.WaitGroup
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(tasks)
+		fetchErr <- p.fetch(runCtx, tracker, slots, tasks)
+	}()
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				started := time.Now()
+				p.reporter.DataFramesInFlight(1)
+				handleCtx, span := p.startSpan(tracing.Extract(workCtx, t.msg.Headers), "pipeline.handle", t.msg)
+				output, err := p.handle(handleCtx, t.msg)
+				tracing.End(span, err)
+				p.reporter.DataFramesInFlight(-1)
+
+				if err == nil {
+					p.reporter.DataFrameProcessed(processingKind(output), time.Since(started))
+				}
+
+				t.span = span.SpanContext()
+				outcomes <- outcome{task: t, output: output, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	var (
+		runErr  error
+		b       batch
+		timer   *time.Timer
+		timeout <-chan time.Time
+	)
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+
+		if runErr != nil || b.empty() {
+			return
+		}
+
+		if workCtx.Err() != nil {
+			// The pipeline has been stopped and there is no time left to flush the batch.
+			// The messages are not committed and will be redelivered.
+			return
+		}
+
+		if err := p.flush(workCtx, tracker, &b); err != nil {
+			if ctx.Err() != nil && workCtx.Err() != nil {
+				// The flush has been interrupted by the pipeline shutdown.
+				// The messages are not committed and will be redelivered.
+				return
+			}
+			runErr = err
+			stop()
+			return
+		}
+
+		for range b.tasks {
+			<-slots
+		}
+		b.reset()
+	}
+
+	for outcomes != nil {
+		select {
+		case o, ok := <-outcomes:
+			if !ok {
+				outcomes = nil
+				continue
+			}
+
+			if runErr != nil {
+				// The pipeline is stopping, the remaining messages
+				// are not committed and will be redelivered.
+				continue
+			}
+
+			if o.err != nil {
+				if ctx.Err() != nil && failure.KindOf(o.err) == failure.KindCanceled {
+					// The pipeline has been stopped in the middle of the message handling.
+					// The message is not committed and will be redelivered.
+					continue
+				}
+				runErr = o.err
+				stop()
+				continue
+			}
+
+			b.add(o.task, o.output)
+			if b.size() >= batchSize {
+				flush()
+			} else if timer == nil {
+				timer = time.NewTimer(p.config.batchTimeout())
+				timeout = timer.C
+			}
+
+		case <-timeout:
+			flush()
+		}
+	}
+
+	// Flush the messages that have been drained.
+	flush()
+
+	if err := <-fetchErr; runErr == nil && err != nil {
+		runErr = err
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+
+	log.Info("Pipeline has been stopped.")
+	return nil
+}
+
+// drainContext returns the context for handling the in-flight messages.
+// The returned context keeps the values of the parent context,
+// and it is canceled once the grace period is over after the parent is canceled.
+func (p *Pipeline) drainContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if p.config.GracePeriod <= 0 {
+		return context.WithCancel(parent)
+	}
+
+	ctx, cancel := context.WithCancel(detachedContext{parent})
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-parent.Done():
+		}
+
+		timer := time.NewTimer(p.config.GracePeriod)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+			cancel()
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// detachedContext keeps the values of the parent context,
+// but it is not canceled when the parent is canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// outcome is the result of the message handling.
+type outcome struct {
+	task *task
+
+	// output is the message that should be written to the writer,
+	// nil if the message has been rejected.
+	output *kafka.Message
+
+	err error
+}
+
+// fetch fetches the messages from the reader, and sends them to the workers,
+// until the context is canceled or the reader fails.
+// A slot is taken before each fetch, limiting the number of in-flight messages.
+func (p *Pipeline) fetch(ctx context.Context, tracker *offsetTracker, slots chan struct{}, tasks chan<- *task) error {
+	log := p.log.WithField(logger.FieldFunction, "Pipeline.fetch")
+
+	sleeper := p.newSleeper()
 	failedFetches := 0
 	for {
 		select {
 		case <-ctx.Done():
-			log.Info("Pipeline has been stopped.")
 			return nil
-		default:
+		case slots <- struct{}{}:
 			// Nop
 		}
 
+		// The context could be canceled while waiting for the slot.
+		if ctx.Err() != nil {
+			return nil
+		}
+
 		log.Info("Fetching the next message from the reader.")
+		started := time.Now()
 		m, err := p.reader.FetchMessage(ctx)
 		if err != nil {
+			<-slots
+			if ctx.Err() != nil && failure.KindOf(err) == failure.KindCanceled {
+				return nil
+			}
+
 			log.Error(err, "Failed to fetch a message from the kafka reader")
+			p.reporter.PipelineFailed(StageFetch)
 
 			failedFetches += 1
 			if failedFetches >= retryFetchCount {
@@ -181,79 +639,322 @@ func (p *Pipeline) Run(ctx context.Context) error {
 					"Giving up fetching the message. Stopping pipeline because of %d consecutive failed fetches",
 					retryFetchCount)
 				return err
-			} else {
-				p.sleeper.Sleep()
-				continue
 			}
+			p.reporter.RetryAttempted(StageFetch)
+			sleeper.Sleep(ctx)
+			continue
 		}
 		failedFetches = 0
+		sleeper.Reset()
 		log.Info("Fetched a new message")
+		p.reporter.ProcessingFinished(StageFetch, time.Since(started))
+		p.reporter.BytesTransferred(StageFetch, messageSize(m))
+		if m.HighWaterMark > 0 {
+			// The lag is tracked for each partition that is assigned to the reader,
+			// unlike the kafka reader stats of a consumer group.
+			p.reporter.ConsumerLag(m.Topic, strconv.Itoa(m.Partition), m.HighWaterMark-m.Offset-1)
+		}
 
-		frame := &api.InputFrame{}
-		if err := frame.Unmarshal(m.Value); err != nil {
-			// TODO: Drop the data frame and move on
-			// TODO: Reflect the failure in the stream?
-			continue
+		// The fetch span is a part of the message trace,
+		// so it is recorded once the message is fetched.
+		_, span := p.startSpan(tracing.Extract(ctx, m.Headers), "pipeline.fetch", m, trace.WithTimestamp(started))
+		span.End()
+
+		t := tracker.track(m)
+		select {
+		case tasks <- t:
+		case <-ctx.Done():
+			return nil
 		}
+	}
+}
 
-		converted_blob, err := p.processor.Process(ctx, frame)
-		if err != nil {
-			// TODO: handle different reasons of failure
-			continue
+// handle processes the message and returns the converted blob message,
+// that should be written to the writer.
+// The messages that could not be processed are rejected, and no message is returned.
+// It returns an error if the pipeline should be stopped.
+// Once handle returns no error, the message could be committed.
+func (p *Pipeline) handle(ctx context.Context, m kafka.Message) (*kafka.Message, error) {
+	log := p.log.WithFields(logger.Fields{
+		logger.FieldFunction: "Pipeline.handle",
+		"partition":          m.Partition,
+		"offset":             m.Offset,
+	})
+
+	frame := &api.InputFrame{}
+	if err := frame.Unmarshal(m.Value); err != nil {
+		log.Error(err, "Failed to unmarshal the data frame.")
+		p.reporter.PipelineFailed(StageUnmarshal)
+		return nil, p.reject(ctx, m, StageUnmarshal, failure.Corrupt(err), 1)
+	}
+
+	// The message time is the same for the redelivered message,
+	// unlike the processing time, so the data frame keeps its time.
+	if frame.TimestampMs == 0 && !m.Time.IsZero() {
+		frame.TimestampMs = m.Time.UnixMilli()
+	}
+
+	started := time.Now()
+	converted_blob, attempts, err := p.process(ctx, frame)
+	duration := time.Since(started)
+	if err != nil {
+		switch failure.KindOf(err) {
+		case failure.KindCanceled:
+			log.Error(err, "Data frame processing has been canceled.")
+			return nil, err
+
+		case failure.KindTransient:
+			log.Errorf(err,
+				"Giving up processing the data frame. Stopping pipeline because of %d consecutive transient failures",
+				attempts)
+			return nil, err
+
+		default:
+			return nil, p.reject(ctx, m, StageProcess, err, attempts)
 		}
+	}
 
-		bytes, err := converted_blob.Marshal()
-		if err != nil {
-			// TODO: Drop the data frame and move on
-			// TODO: Reflect the failure in the stream?
-			continue
+	p.reporter.ProcessingFinished(StageProcess, duration)
+	p.reporter.DataFrameConverted(converted_blob.FrameSizeBytes, converted_blob.SizeBytes)
+
+	bytes, err := converted_blob.Marshal()
+	if err != nil {
+		log.Error(err, "Failed to marshal the converted blob.")
+		p.reporter.PipelineFailed(StageMarshal)
+		return nil, p.reject(ctx, m, StageMarshal, err, 1)
+	}
+
+	output := p.outputMessage(ctx, m, converted_blob.FrameId, bytes, duration)
+	return &output, nil
+}
+
+// process processes the data frame, retrying the transient
+// and unclassified failures. It returns the converted blob
+// or the last failure, and the number of attempts.
+func (p *Pipeline) process(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, int, error) {
+	log := p.log.WithFields(logger.Fields{
+		logger.FieldFunction: "Pipeline.process",
+		"frame":              frame.FrameId,
+	})
+
+	sleeper := p.newSleeper()
+	attempts := 0
+	for {
+		attempts += 1
+		blob, err := p.processor.Process(ctx, frame)
+		if err == nil {
+			return blob, attempts, nil
 		}
 
-		msg := kafka.Message{
-			Key:   []byte(converted_blob.FrameId),
-			Value: bytes,
+		kind := failure.KindOf(err)
+		log.ErrorWithFields(err, logger.Fields{"failure": kind.String()}, "Failed to process the data frame.")
+		p.reporter.PipelineFailed(StageProcess + "_" + kind.String())
+
+		if kind != failure.KindTransient && kind != failure.KindUnknown {
+			return nil, attempts, err
 		}
 
-		messageWritten := false
-		writeAttempt := 0
-		for !messageWritten {
-			if err := p.writer.WriteMessages(ctx, msg); err != nil {
-				log.Error(err, "Failed to write the message to the kafka writer")
-
-				writeAttempt += 1
-				if writeAttempt >= retryWriteCount {
-					log.Errorf(err,
-						"Giving up writing the message. Stopping pipeline because of %d consecutive failed writes",
-						retryWriteCount)
-					return err
-				} else {
-					p.sleeper.Sleep()
-					continue
-				}
-			}
-			messageWritten = true
+		if attempts >= retryProcessCount || ctx.Err() != nil {
+			return nil, attempts, err
 		}
+		p.reporter.RetryAttempted(StageProcess)
+		sleeper.Sleep(ctx)
+	}
+}
 
-		readCommitted := false
-		commitAttempt := 0
-		for !readCommitted {
-			if err := p.reader.CommitMessages(ctx, m); err != nil {
-				log.Error(err, "Failed to commit read message to the kafka reader")
-
-				commitAttempt += 1
-				if commitAttempt >= retryCommitCount {
-					log.Errorf(err,
-						"Giving up committing the message. Stopping pipeline because of %d consecutive failed commits",
-						retryCommitCount)
-					return err
-				} else {
-					p.sleeper.Sleep()
-					continue
-				}
+// reject sends the message that the pipeline has failed to handle
+// to the dead letter writer, so the message could be committed
+// and the pipeline could move forward.
+// If there is no dead letter writer, the message is dropped.
+func (p *Pipeline) reject(ctx context.Context, m kafka.Message, stage string, failure error, attempts int) error {
+	log := p.log.WithFields(logger.Fields{
+		logger.FieldFunction: "Pipeline.reject",
+		"stage":              stage,
+		"partition":          m.Partition,
+		"offset":             m.Offset,
+	})
+
+	if p.deadLetter == nil {
+		log.Warn("Dropping the message, no dead letter writer provided.")
+		return nil
+	}
+
+	if err := p.write(ctx, p.deadLetter, StageDeadLetter, p.deadLetterMessage(m, stage, failure, attempts)); err != nil {
+		return err
+	}
+	p.reporter.DeadLettered(stage)
+
+	log.Info("Sent the message to the dead letter writer.")
+	return nil
+}
+
+// deadLetterMessage creates a dead letter message from the original message,
+// adding the headers that describe the failure.
+func (p *Pipeline) deadLetterMessage(m kafka.Message, stage string, failure error, attempts int) kafka.Message {
+	headers := make([]kafka.Header, 0, len(m.Headers)+8)
+	headers = append(headers, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderFailureStage, Value: []byte(stage)},
+		kafka.Header{Key: HeaderFailureError, Value: []byte(failure.Error())},
+		kafka.Header{Key: HeaderFailureAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderFailureTime, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+		kafka.Header{Key: HeaderPipelineID, Value: []byte(p.id)},
+		kafka.Header{Key: HeaderSourceTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderSourcePartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderSourceOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+	)
+
+	return kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	}
+}
+
+// outputMessage creates the converted blob message, propagating the headers
+// of the original message, e.g. the correlation id and the trace context,
+// and adding the headers that identify the original message and its processing.
+// The pipeline headers and the trace context of the original message are replaced,
+// so the output message continues the trace of the message handling.
+func (p *Pipeline) outputMessage(
+	ctx context.Context,
+	m kafka.Message,
+	frameID string,
+	value []byte,
+	duration time.Duration,
+) kafka.Message {
+	headers := make([]kafka.Header, 0, len(m.Headers)+6)
+	for _, h := range m.Headers {
+		if !strings.HasPrefix(h.Key, headerPrefix) {
+			headers = append(headers, h)
+		}
+	}
+
+	headers = append(headers,
+		kafka.Header{Key: HeaderPipelineID, Value: []byte(p.id)},
+		kafka.Header{Key: HeaderSourceTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderSourcePartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderSourceOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderProcessingDuration, Value: []byte(strconv.FormatFloat(milliseconds(duration), 'f', 3, 64))},
+	)
+	if p.config.ConverterKind != "" {
+		headers = append(headers, kafka.Header{Key: HeaderConverterKind, Value: []byte(p.config.ConverterKind)})
+	}
+
+	return kafka.Message{
+		Key:     []byte(frameID),
+		Value:   value,
+		Headers: tracing.Inject(ctx, headers),
+	}
+}
+
+// startSpan starts the span of the message handling stage.
+func (p *Pipeline) startSpan(
+	ctx context.Context,
+	name string,
+	m kafka.Message,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	opts = append(opts,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKey.String(m.Topic),
+			semconv.MessagingKafkaPartitionKey.Int(m.Partition),
+			attribute.Int64("messaging.kafka.message_offset", m.Offset),
+			attribute.String("pipeline.id", p.id),
+		),
+	)
+	return p.tracer.Start(ctx, name, opts...)
+}
+
+// write writes the messages to the writer,
+// retrying the failed writes before giving up.
+// The stage identifies the writer in the reported metrics.
+func (p *Pipeline) write(ctx context.Context, writer Writer, stage string, msgs ...kafka.Message) error {
+	log := p.log.WithField(logger.FieldFunction, "Pipeline.write")
+
+	sleeper := p.newSleeper()
+	started := time.Now()
+	writeAttempt := 0
+	for {
+		err := writer.WriteMessages(ctx, msgs...)
+		if err == nil {
+			p.reporter.ProcessingFinished(stage, time.Since(started))
+			for _, m := range msgs {
+				p.reporter.BytesTransferred(stage, messageSize(m))
 			}
-			readCommitted = true
+			return nil
 		}
+		log.Error(err, "Failed to write the message to the kafka writer")
+		p.reporter.PipelineFailed(stage)
 
-		p.sleeper.Reset()
+		if ctx.Err() != nil {
+			// The write is not retried once the pipeline is stopped.
+			return err
+		}
+
+		writeAttempt += 1
+		if writeAttempt >= retryWriteCount {
+			log.Errorf(err,
+				"Giving up writing the message. Stopping pipeline because of %d consecutive failed writes",
+				retryWriteCount)
+			return err
+		}
+		p.reporter.RetryAttempted(stage)
+		sleeper.Sleep(ctx)
 	}
 }
+
+// commit commits the messages to the reader,
+// retrying the failed commits before giving up.
+func (p *Pipeline) commit(ctx context.Context, msgs ...kafka.Message) error {
+	log := p.log.WithField(logger.FieldFunction, "Pipeline.commit")
+
+	sleeper := p.newSleeper()
+	started := time.Now()
+	commitAttempt := 0
+	for {
+		err := p.reader.CommitMessages(ctx, msgs...)
+		if err == nil {
+			p.reporter.ProcessingFinished(StageCommit, time.Since(started))
+			return nil
+		}
+		log.Error(err, "Failed to commit read message to the kafka reader")
+		p.reporter.PipelineFailed(StageCommit)
+
+		if ctx.Err() != nil {
+			// The commit is not retried once the pipeline is stopped.
+			return err
+		}
+
+		commitAttempt += 1
+		if commitAttempt >= retryCommitCount {
+			log.Errorf(err,
+				"Giving up committing the message. Stopping pipeline because of %d consecutive failed commits",
+				retryCommitCount)
+			return err
+		}
+		p.reporter.RetryAttempted(StageCommit)
+		sleeper.Sleep(ctx)
+	}
+}
+
+// processingKind returns the processing kind of the handled data frame,
+// that is rejected if there is no converted blob message.
+func processingKind(output *kafka.Message) string {
+	if output == nil {
+		return ProcessingRejected
+	}
+	return ProcessingConverted
+}
+
+// messageSize returns the size of the message key and value in bytes.
+func messageSize(m kafka.Message) int {
+	return len(m.Key) + len(m.Value)
+}
+
+// milliseconds returns the duration in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
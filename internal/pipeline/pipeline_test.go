@@ -2,16 +2,29 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	api "github.com/weak-head/data-pipe/api/v1"
 	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/processor"
+	"github.com/weak-head/data-pipe/internal/sleeper"
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/internal/stream"
+	"github.com/weak-head/data-pipe/pkg/failure"
 
 	kafka "github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestPipelineCreation(t *testing.T) {
@@ -29,6 +42,7 @@ func TestPipelineCreation(t *testing.T) {
 		"fails to create if no sleeper provided":   testFailsIfNoSleeper,
 		"fails to create if no processor provided": testFailsIfNoProcessor,
 		"fails to create if no reporter provided":  testFailsIfNoReporter,
+		"creates pipelines with unique ids":        testCreatesUniqueIds,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			reader := &readerMock{
@@ -69,6 +83,7 @@ func TestPipelineFlow(t *testing.T) {
 		"pipeline exits on N consecutive fetch errors":  testExitOnFetchErrors,
 		"pipeline exits on N consecutive write errors":  testExitOnWriteErrors,
 		"pipeline exits on N consecutive commit errors": testExitOnCommitErrors,
+		"sets the frame time from the message time":     testSetsFrameTime,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			reader := &readerMock{
@@ -88,10 +103,12 @@ func TestPipelineFlow(t *testing.T) {
 			logger, hook := logger.NewNullLogger()
 
 			pipeline, err := NewPipeline(
+				Config{},
 				reader,
 				writer,
+				nil,
 				processor,
-				sleeper,
+				sleeper.new,
 				reporter,
 				logger,
 			)
@@ -110,18 +127,26 @@ type readerMock struct {
 		error
 	}
 
+	respectCtx   bool
 	commitCount  int
 	commitHook   func(msgs ...kafka.Message)
 	commitResult error
 }
 
 type writerMock struct {
+	mu          sync.Mutex
+	respectCtx  bool
 	writeCount  int
 	writeHook   func(msgs ...kafka.Message)
 	writeResult error
 }
 
 type processorMock struct {
+	frames        []*api.InputFrame
+	processCount  int
+	processHook   func()
+	processResult error
+	processBlob   *api.ConvertedBlob
 }
 
 type sleeperMock struct {
@@ -130,6 +155,18 @@ type sleeperMock struct {
 }
 
 type reporterMock struct {
+	mu          sync.Mutex
+	processed   map[string]int
+	finished    map[string]int
+	failures    map[string]int
+	retries     map[string]int
+	bytes       map[string]int
+	deadLetters map[string]int
+	frameBytes  []int64
+	blobBytes   []int64
+	inFlight    []int
+	running     []int
+	lag         map[string]int64
 }
 
 func (r *readerMock) FetchMessage(ctx context.Context) (kafka.Message, error) {
@@ -143,6 +180,9 @@ func (r *readerMock) FetchMessage(ctx context.Context) (kafka.Message, error) {
 
 func (r *readerMock) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
 	r.commitCount++
+	if r.respectCtx && ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if r.commitHook != nil {
 		r.commitHook(msgs...)
 	}
@@ -150,6 +190,11 @@ func (r *readerMock) CommitMessages(ctx context.Context, msgs ...kafka.Message)
 }
 
 func (w *writerMock) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.respectCtx && ctx.Err() != nil {
+		return ctx.Err()
+	}
 	w.writeCount += len(msgs)
 	if w.writeHook != nil {
 		w.writeHook(msgs...)
@@ -158,10 +203,26 @@ func (w *writerMock) WriteMessages(ctx context.Context, msgs ...kafka.Message) e
 }
 
 func (p *processorMock) Process(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
-	return &api.ConvertedBlob{}, nil
+	p.processCount++
+	p.frames = append(p.frames, frame)
+	if p.processHook != nil {
+		p.processHook()
+	}
+	if p.processResult != nil {
+		return nil, p.processResult
+	}
+	if p.processBlob != nil {
+		return p.processBlob, nil
+	}
+	return &api.ConvertedBlob{FrameId: frame.FrameId}, nil
+}
+
+// new returns the mock itself, so the sleeps of all attempt loops are counted.
+func (s *sleeperMock) new() Sleeper {
+	return s
 }
 
-func (s *sleeperMock) Sleep() {
+func (s *sleeperMock) Sleep(ctx context.Context) {
 	s.sleepCount++
 }
 
@@ -169,9 +230,66 @@ func (s *sleeperMock) Reset() {
 	s.resetCount++
 }
 
-func (r *reporterMock) DataFrameProcessed(processingKind string, milliseconds float64) {}
-func (r *reporterMock) ConvertionFinished(processingKind string, milliseconds float64) {}
-func (r *reporterMock) PipelineFailed(failure string) {}
+func (r *reporterMock) DataFrameProcessed(processingKind string, duration time.Duration) {
+	r.count(&r.processed, processingKind, 1)
+}
+
+func (r *reporterMock) ProcessingFinished(processingKind string, duration time.Duration) {
+	r.count(&r.finished, processingKind, 1)
+}
+
+func (r *reporterMock) PipelineFailed(failure string) {
+	r.count(&r.failures, failure, 1)
+}
+
+func (r *reporterMock) RetryAttempted(stage string) {
+	r.count(&r.retries, stage, 1)
+}
+
+func (r *reporterMock) BytesTransferred(stage string, bytes int) {
+	r.count(&r.bytes, stage, bytes)
+}
+
+func (r *reporterMock) DeadLettered(stage string) {
+	r.count(&r.deadLetters, stage, 1)
+}
+
+func (r *reporterMock) DataFrameConverted(frameBytes int64, blobBytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frameBytes = append(r.frameBytes, frameBytes)
+	r.blobBytes = append(r.blobBytes, blobBytes)
+}
+
+func (r *reporterMock) DataFramesInFlight(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight = append(r.inFlight, delta)
+}
+
+func (r *reporterMock) PipelinesRunning(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = append(r.running, delta)
+}
+
+func (r *reporterMock) ConsumerLag(topic string, partition string, lag int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lag == nil {
+		r.lag = make(map[string]int64)
+	}
+	r.lag[partition] = lag
+}
+
+func (r *reporterMock) count(m *map[string]int, key string, n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if *m == nil {
+		*m = make(map[string]int)
+	}
+	(*m)[key] += n
+}
 
 func testExitOnContext(
 	t *testing.T,
@@ -222,6 +340,39 @@ func testExitAfterCycle(
 	require.Equal(t, "Pipeline has been stopped.", l.LastEntry().Message)
 }
 
+func testSetsFrameTime(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	messageTime := time.Date(2021, time.June, 1, 10, 0, 0, 0, time.UTC)
+	frameTime := messageTime.Add(-time.Hour).UnixMilli()
+
+	withoutTime, err := (&api.InputFrame{FrameId: "frame_1"}).Marshal()
+	require.NoError(t, err)
+	withTime, err := (&api.InputFrame{FrameId: "frame_2", TimestampMs: frameTime}).Marshal()
+	require.NoError(t, err)
+
+	r.fetchResult.Message = kafka.Message{Value: withoutTime, Time: messageTime}
+	r.commitHook = func(msgs ...kafka.Message) {
+		if r.commitCount == 2 {
+			cancel()
+		}
+		r.fetchResult.Message = kafka.Message{Value: withTime, Time: messageTime}
+	}
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Len(t, p.frames, 2)
+	require.Equal(t, messageTime.UnixMilli(), p.frames[0].TimestampMs)
+	require.Equal(t, frameTime, p.frames[1].TimestampMs)
+}
+
 func testTracksErrorOnFetch(
 	t *testing.T,
 	r *readerMock,
@@ -385,10 +536,12 @@ func testFailsIfNoReader(
 	l logger.Log,
 ) {
 	pipeline, err := NewPipeline(
+		Config{},
 		nil,
 		w,
+		nil,
 		p,
-		s,
+		s.new,
 		m,
 		l,
 	)
@@ -396,6 +549,24 @@ func testFailsIfNoReader(
 	require.Equal(t, ErrNoReaderProvided, err)
 }
 
+func testCreatesUniqueIds(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	m *reporterMock,
+	l logger.Log,
+) {
+	ids := make(map[string]bool)
+	for i := 0; i < maxPipelines+1; i++ {
+		pipeline, err := NewPipeline(Config{}, r, w, nil, p, s.new, m, l)
+		require.NoError(t, err)
+		require.False(t, ids[pipeline.ID()], pipeline.ID())
+		ids[pipeline.ID()] = true
+	}
+}
+
 func testFailsIfNoWriter(
 	t *testing.T,
 	r *readerMock,
@@ -406,10 +577,12 @@ func testFailsIfNoWriter(
 	l logger.Log,
 ) {
 	pipeline, err := NewPipeline(
+		Config{},
 		r,
 		nil,
+		nil,
 		p,
-		s,
+		s.new,
 		m,
 		l,
 	)
@@ -427,10 +600,12 @@ func testFailsIfNoProcessor(
 	l logger.Log,
 ) {
 	pipeline, err := NewPipeline(
+		Config{},
 		r,
 		w,
 		nil,
-		s,
+		nil,
+		s.new,
 		m,
 		l,
 	)
@@ -448,8 +623,10 @@ func testFailsIfNoSleeper(
 	l logger.Log,
 ) {
 	pipeline, err := NewPipeline(
+		Config{},
 		r,
 		w,
+		nil,
 		p,
 		nil,
 		m,
@@ -469,13 +646,1189 @@ func testFailsIfNoReporter(
 	l logger.Log,
 ) {
 	pipeline, err := NewPipeline(
+		Config{},
 		r,
 		w,
+		nil,
 		p,
-		s,
+		s.new,
 		nil,
 		l,
 	)
 	require.Nil(t, pipeline)
 	require.Equal(t, ErrNoReporterProvided, err)
 }
+
+func TestPipelineDeadLetter(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		r *readerMock,
+		w *writerMock,
+		d *writerMock,
+		p *processorMock,
+		s *sleeperMock,
+		l *logtest.Hook,
+		pipeline *Pipeline,
+	){
+		"sends undecodable frame to dead letter": testDeadLetterOnUnmarshal,
+		"sends failed frame to dead letter":      testDeadLetterOnProcess,
+		"retries unclassified failures":          testRetriesUnknownFailures,
+		"recovers from transient failures":       testRecoversFromTransientFailures,
+		"pipeline exits on transient failures":   testExitOnTransientFailures,
+		"pipeline exits on canceled processing":  testExitOnCanceledProcessing,
+		"pipeline exits on dead letter errors":   testExitOnDeadLetterErrors,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			frame, err := (&api.InputFrame{FrameId: "frame_1"}).Marshal()
+			require.NoError(t, err)
+
+			reader := &readerMock{
+				fetchResult: struct {
+					kafka.Message
+					error
+				}{
+					Message: kafka.Message{
+						Topic:     "frames",
+						Partition: 2,
+						Offset:    42,
+						Key:       []byte("frame_1"),
+						Value:     frame,
+						Headers:   []kafka.Header{{Key: "trace", Value: []byte("t1")}},
+					},
+				},
+			}
+			writer := &writerMock{}
+			deadLetter := &writerMock{}
+			processor := &processorMock{}
+			sleeper := &sleeperMock{}
+			log, hook := logger.NewNullLogger()
+
+			pipeline, err := NewPipeline(
+				Config{},
+				reader,
+				writer,
+				deadLetter,
+				processor,
+				sleeper.new,
+				&reporterMock{},
+				log,
+			)
+			require.NoError(t, err)
+
+			fn(t, reader, writer, deadLetter, processor, sleeper, hook, pipeline)
+		})
+	}
+
+	t.Run("drops failed frame without dead letter", testDropsWithoutDeadLetter)
+}
+
+func TestPipelineReporting(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		r *readerMock,
+		w *writerMock,
+		d *writerMock,
+		p *processorMock,
+		m *reporterMock,
+		pipeline *Pipeline,
+	){
+		"reports converted frame":     testReportsConvertedFrame,
+		"reports rejected frame":      testReportsRejectedFrame,
+		"reports processing failures": testReportsProcessingFailures,
+		"reports fetch failures":      testReportsFetchFailures,
+		"reports write failures":      testReportsWriteFailures,
+		"reports commit failures":     testReportsCommitFailures,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			frame, err := (&api.InputFrame{FrameId: "frame_1"}).Marshal()
+			require.NoError(t, err)
+
+			reader := &readerMock{}
+			reader.fetchResult.Message = kafka.Message{Topic: "frames", Key: []byte("frame_1"), Value: frame}
+			writer := &writerMock{}
+			deadLetter := &writerMock{}
+			processor := &processorMock{}
+			reporter := &reporterMock{}
+			log, _ := logger.NewNullLogger()
+
+			pipeline, err := NewPipeline(
+				Config{},
+				reader,
+				writer,
+				deadLetter,
+				processor,
+				(&sleeperMock{}).new,
+				reporter,
+				log,
+			)
+			require.NoError(t, err)
+
+			fn(t, reader, writer, deadLetter, processor, reporter, pipeline)
+		})
+	}
+}
+
+func testReportsConvertedFrame(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	m *reporterMock,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.commitHook = func(msgs ...kafka.Message) { cancel() }
+	p.processBlob = &api.ConvertedBlob{FrameId: "frame_1", SizeBytes: 3, FrameSizeBytes: 5}
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, map[string]int{ProcessingConverted: 1}, m.processed)
+	require.Equal(t, map[string]int{
+		StageFetch:   1,
+		StageProcess: 1,
+		StageWrite:   1,
+		StageCommit:  1,
+	}, m.finished)
+	require.Empty(t, m.failures)
+	require.Empty(t, m.retries)
+	require.Empty(t, m.deadLetters)
+
+	require.Equal(t, len(r.fetchResult.Key)+len(r.fetchResult.Value), m.bytes[StageFetch])
+	require.Greater(t, m.bytes[StageWrite], 0)
+	require.Equal(t, []int64{5}, m.frameBytes)
+	require.Equal(t, []int64{3}, m.blobBytes)
+	require.Equal(t, []int{1, -1}, m.inFlight)
+	require.Equal(t, []int{1, -1}, m.running)
+}
+
+func testReportsRejectedFrame(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	m *reporterMock,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.fetchResult.Value = []byte{0xff}
+	r.commitHook = func(msgs ...kafka.Message) { cancel() }
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, map[string]int{ProcessingRejected: 1}, m.processed)
+	require.Equal(t, map[string]int{
+		StageFetch:      1,
+		StageDeadLetter: 1,
+		StageCommit:     1,
+	}, m.finished)
+	require.Equal(t, map[string]int{StageUnmarshal: 1}, m.failures)
+	require.Equal(t, map[string]int{StageUnmarshal: 1}, m.deadLetters)
+	require.Greater(t, m.bytes[StageDeadLetter], 0)
+	require.Empty(t, m.frameBytes)
+}
+
+func testReportsProcessingFailures(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	m *reporterMock,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.processResult = fmt.Errorf("unexpected failure")
+	r.commitHook = func(msgs ...kafka.Message) { cancel() }
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, map[string]int{ProcessingRejected: 1}, m.processed)
+	require.Equal(t, map[string]int{"process_unknown": retryProcessCount}, m.failures)
+	require.Equal(t, map[string]int{StageProcess: retryProcessCount - 1}, m.retries)
+	require.Equal(t, map[string]int{StageProcess: 1}, m.deadLetters)
+	require.NotContains(t, m.finished, StageProcess)
+}
+
+func testReportsFetchFailures(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	m *reporterMock,
+	pipeline *Pipeline,
+) {
+	r.fetchResult.error = fmt.Errorf("Invalid hostname")
+
+	require.Error(t, pipeline.Run(context.Background()))
+
+	require.Equal(t, map[string]int{StageFetch: retryFetchCount}, m.failures)
+	require.Equal(t, map[string]int{StageFetch: retryFetchCount - 1}, m.retries)
+	require.Equal(t, []int{1, -1}, m.running)
+	require.Empty(t, m.finished)
+	require.Empty(t, m.processed)
+}
+
+func testReportsWriteFailures(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	m *reporterMock,
+	pipeline *Pipeline,
+) {
+	w.writeResult = fmt.Errorf("Invalid hostname")
+
+	require.Error(t, pipeline.Run(context.Background()))
+
+	require.Equal(t, map[string]int{StageWrite: retryWriteCount}, m.failures)
+	require.Equal(t, map[string]int{StageWrite: retryWriteCount - 1}, m.retries)
+	require.NotContains(t, m.finished, StageWrite)
+	require.NotContains(t, m.finished, StageCommit)
+}
+
+func testReportsCommitFailures(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	m *reporterMock,
+	pipeline *Pipeline,
+) {
+	r.commitResult = fmt.Errorf("Invalid hostname")
+
+	require.Error(t, pipeline.Run(context.Background()))
+
+	require.Equal(t, map[string]int{StageCommit: retryCommitCount}, m.failures)
+	require.Equal(t, map[string]int{StageCommit: retryCommitCount - 1}, m.retries)
+	require.Equal(t, 1, m.finished[StageWrite])
+	require.NotContains(t, m.finished, StageCommit)
+}
+
+func TestPipelineConsumerLag(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic := stream.NewMemoryTopic("frames", 1)
+	produceFrame(t, topic, "a")
+	produceFrame(t, topic, "b")
+	produceFrame(t, topic, "c")
+
+	// The pipeline is busy with the first frame,
+	// so the following frames are not fetched yet.
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	proc := processorFunc(func(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+		started <- struct{}{}
+		<-release
+		return &api.ConvertedBlob{FrameId: frame.FrameId}, nil
+	})
+
+	reporter := &reporterMock{}
+	log, _ := logger.NewNullLogger()
+	p, err := NewPipeline(
+		Config{},
+		topic.NewReader(), &writerMock{}, nil, proc, (&sleeperMock{}).new, reporter, log,
+	)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	<-started
+	reporter.mu.Lock()
+	require.Equal(t, map[string]int64{"0": 2}, reporter.lag)
+	reporter.mu.Unlock()
+
+	close(release)
+	require.Eventually(t, func() bool {
+		reporter.mu.Lock()
+		defer reporter.mu.Unlock()
+		return reporter.lag["0"] == 0
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func headerValue(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func testDeadLetterOnUnmarshal(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.fetchResult.Value = []byte{0xff}
+
+	var deadLetters []kafka.Message
+	d.writeHook = func(msgs ...kafka.Message) { deadLetters = append(deadLetters, msgs...) }
+
+	var committed []kafka.Message
+	r.commitHook = func(msgs ...kafka.Message) {
+		committed = append(committed, msgs...)
+		cancel()
+	}
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, 0, p.processCount)
+	require.Equal(t, 0, w.writeCount)
+	require.Equal(t, 1, d.writeCount)
+	require.Equal(t, 1, r.commitCount)
+	require.Equal(t, int64(42), committed[0].Offset)
+
+	m := deadLetters[0]
+	require.Equal(t, r.fetchResult.Key, m.Key)
+	require.Equal(t, r.fetchResult.Value, m.Value)
+	require.Equal(t, "t1", headerValue(m, "trace"))
+	require.Equal(t, StageUnmarshal, headerValue(m, HeaderFailureStage))
+	require.NotEmpty(t, headerValue(m, HeaderFailureError))
+	require.Equal(t, "1", headerValue(m, HeaderFailureAttempts))
+	require.NotEmpty(t, headerValue(m, HeaderFailureTime))
+	require.Equal(t, pipeline.id, headerValue(m, HeaderPipelineID))
+	require.Equal(t, "frames", headerValue(m, HeaderSourceTopic))
+	require.Equal(t, "2", headerValue(m, HeaderSourcePartition))
+	require.Equal(t, "42", headerValue(m, HeaderSourceOffset))
+}
+
+func testDeadLetterOnProcess(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.processResult = failure.Rejected(fmt.Errorf("conversion failed"))
+
+	var deadLetters []kafka.Message
+	d.writeHook = func(msgs ...kafka.Message) { deadLetters = append(deadLetters, msgs...) }
+	r.commitHook = func(msgs ...kafka.Message) { cancel() }
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, 1, p.processCount)
+	require.Equal(t, 0, w.writeCount)
+	require.Equal(t, 1, d.writeCount)
+	require.Equal(t, 1, r.commitCount)
+
+	require.Equal(t, StageProcess, headerValue(deadLetters[0], HeaderFailureStage))
+	require.Equal(t, "conversion failed", headerValue(deadLetters[0], HeaderFailureError))
+}
+
+func testExitOnDeadLetterErrors(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	p.processResult = failure.Rejected(fmt.Errorf("conversion failed"))
+	d.writeResult = fmt.Errorf("Invalid hostname")
+
+	err := pipeline.Run(context.Background())
+	require.Equal(t, d.writeResult, err)
+
+	require.Equal(t, retryWriteCount, d.writeCount)
+	require.Equal(t, 0, w.writeCount)
+	require.Equal(t, 0, r.commitCount)
+}
+
+func testRetriesUnknownFailures(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.processResult = fmt.Errorf("unexpected failure")
+
+	var deadLetters []kafka.Message
+	d.writeHook = func(msgs ...kafka.Message) { deadLetters = append(deadLetters, msgs...) }
+	r.commitHook = func(msgs ...kafka.Message) { cancel() }
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, retryProcessCount, p.processCount)
+	require.Equal(t, retryProcessCount-1, s.sleepCount)
+	require.Equal(t, 1, d.writeCount)
+	require.Equal(t, 1, r.commitCount)
+	require.Equal(t, fmt.Sprint(retryProcessCount), headerValue(deadLetters[0], HeaderFailureAttempts))
+}
+
+func testRecoversFromTransientFailures(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.processResult = failure.Transient(fmt.Errorf("connection refused"))
+	p.processHook = func() {
+		if p.processCount == retryProcessCount {
+			p.processResult = nil
+		}
+	}
+	r.commitHook = func(msgs ...kafka.Message) { cancel() }
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, retryProcessCount, p.processCount)
+	require.Equal(t, retryProcessCount-1, s.sleepCount)
+	require.Equal(t, 1, w.writeCount)
+	require.Equal(t, 0, d.writeCount)
+	require.Equal(t, 1, r.commitCount)
+}
+
+func testExitOnTransientFailures(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	p.processResult = failure.Transient(fmt.Errorf("connection refused"))
+
+	err := pipeline.Run(context.Background())
+	require.Equal(t, p.processResult, err)
+
+	require.Equal(t, retryProcessCount, p.processCount)
+	require.Equal(t, 0, w.writeCount)
+	require.Equal(t, 0, d.writeCount)
+	require.Equal(t, 0, r.commitCount)
+
+	require.Equal(t, logrus.ErrorLevel, l.LastEntry().Level)
+	require.Equal(
+		t,
+		fmt.Sprintf(
+			"Giving up processing the data frame. Stopping pipeline because of %d consecutive transient failures",
+			retryProcessCount),
+		l.LastEntry().Message)
+}
+
+func testExitOnCanceledProcessing(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	d *writerMock,
+	p *processorMock,
+	s *sleeperMock,
+	l *logtest.Hook,
+	pipeline *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.processHook = cancel
+	p.processResult = failure.Canceled(context.Canceled)
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, 1, p.processCount)
+	require.Equal(t, 0, w.writeCount)
+	require.Equal(t, 0, d.writeCount)
+	require.Equal(t, 0, r.commitCount)
+	require.Equal(t, "Pipeline has been stopped.", l.LastEntry().Message)
+}
+
+func testDropsWithoutDeadLetter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	reader := &readerMock{}
+	reader.fetchResult.Value = []byte{0xff}
+	reader.commitHook = func(msgs ...kafka.Message) { cancel() }
+
+	writer := &writerMock{}
+	log, hook := logger.NewNullLogger()
+
+	pipeline, err := NewPipeline(Config{}, reader, writer, nil, &processorMock{}, (&sleeperMock{}).new, &reporterMock{}, log)
+	require.NoError(t, err)
+
+	require.NoError(t, pipeline.Run(ctx))
+
+	require.Equal(t, 0, writer.writeCount)
+	require.Equal(t, 1, reader.commitCount)
+
+	var dropped bool
+	for _, e := range hook.Entries {
+		if e.Level == logrus.WarnLevel && e.Message == "Dropping the message, no dead letter writer provided." {
+			dropped = true
+		}
+	}
+	require.True(t, dropped)
+}
+
+func TestPipelineConcurrency(t *testing.T) {
+	const frames = 4
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic := stream.NewMemoryTopic("frames", 1)
+	for i := 0; i < frames; i++ {
+		produceFrame(t, topic, fmt.Sprint(i))
+	}
+	reader := topic.NewReader()
+
+	processor := &blockingProcessorMock{
+		started:  make(chan string, frames),
+		released: make(map[string]chan struct{}),
+	}
+	for i := 0; i < frames; i++ {
+		processor.released[fmt.Sprint(i)] = make(chan struct{})
+	}
+
+	reader.CommitHook = func(ctx context.Context, msgs ...kafka.Message) error {
+		for _, m := range msgs {
+			if m.Offset == frames-1 {
+				cancel()
+			}
+		}
+		return nil
+	}
+
+	written := make(chan struct{}, frames)
+	writer := &writerMock{writeHook: func(msgs ...kafka.Message) { written <- struct{}{} }}
+	log, _ := logger.NewNullLogger()
+
+	pipeline, err := NewPipeline(
+		Config{Concurrency: frames},
+		reader,
+		writer,
+		nil,
+		processor,
+		(&sleeperMock{}).new,
+		&reporterMock{},
+		log,
+	)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- pipeline.Run(ctx) }()
+
+	// All frames are processed concurrently.
+	for i := 0; i < frames; i++ {
+		<-processor.started
+	}
+
+	// Nothing is committed until the first frame of the partition is completed.
+	for i := 1; i < frames; i++ {
+		close(processor.released[fmt.Sprint(i)])
+		<-written
+	}
+	require.Equal(t, map[int]int64{0: 0}, topic.Committed())
+
+	close(processor.released["0"])
+
+	require.NoError(t, <-done)
+	require.Equal(t, frames, writer.writeCount)
+	require.Equal(t, map[int]int64{0: frames}, topic.Committed())
+}
+
+func TestPipelineBatching(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		topic *stream.MemoryTopic,
+		w *writerMock,
+	){
+		"writes and commits full batch at once": testWritesFullBatch,
+		"flushes partial batch on timeout":      testFlushesBatchOnTimeout,
+		"leaves partial batch on shutdown":      testLeavesBatchOnShutdown,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			fn(t, stream.NewMemoryTopic("frames", 2), &writerMock{})
+		})
+	}
+}
+
+func newBatchingPipeline(t *testing.T, config Config, r Reader, w *writerMock) *Pipeline {
+	log, _ := logger.NewNullLogger()
+
+	pipeline, err := NewPipeline(
+		config,
+		r,
+		w,
+		nil,
+		processorFunc(func(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+			return &api.ConvertedBlob{FrameId: frame.FrameId}, nil
+		}),
+		(&sleeperMock{}).new,
+		&reporterMock{},
+		log,
+	)
+	require.NoError(t, err)
+	return pipeline
+}
+
+func testWritesFullBatch(t *testing.T, topic *stream.MemoryTopic, w *writerMock) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The frames are distributed between the partitions in the round robin.
+	for i := 0; i < 4; i++ {
+		produceFrame(t, topic, fmt.Sprint(i))
+	}
+	r := topic.NewReader()
+
+	var writes, commits [][]kafka.Message
+	w.writeHook = func(msgs ...kafka.Message) { writes = append(writes, msgs) }
+	r.CommitHook = func(ctx context.Context, msgs ...kafka.Message) error {
+		commits = append(commits, msgs)
+		cancel()
+		return nil
+	}
+
+	p := newBatchingPipeline(t, Config{Concurrency: 2, BatchSize: 4, BatchTimeout: time.Hour}, r, w)
+	require.NoError(t, p.Run(ctx))
+
+	require.Len(t, writes, 1)
+	require.Len(t, writes[0], 4)
+
+	require.Len(t, commits, 1)
+	highest := make(map[int]int64)
+	for _, m := range commits[0] {
+		highest[m.Partition] = m.Offset
+	}
+	require.Equal(t, map[int]int64{0: 1, 1: 1}, highest)
+	require.Equal(t, map[int]int64{0: 2, 1: 2}, topic.Committed())
+}
+
+func testFlushesBatchOnTimeout(t *testing.T, topic *stream.MemoryTopic, w *writerMock) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		produceFrame(t, topic, fmt.Sprint(i))
+	}
+	r := topic.NewReader()
+
+	var written int
+	w.writeHook = func(msgs ...kafka.Message) { written += len(msgs) }
+	r.CommitHook = func(ctx context.Context, msgs ...kafka.Message) error {
+		if written == 2 {
+			cancel()
+		}
+		return nil
+	}
+
+	const timeout = 50 * time.Millisecond
+	p := newBatchingPipeline(t, Config{BatchSize: 10, BatchTimeout: timeout}, r, w)
+
+	started := time.Now()
+	require.NoError(t, p.Run(ctx))
+	require.GreaterOrEqual(t, int64(time.Since(started)), int64(timeout))
+
+	require.Equal(t, 2, written)
+	require.Equal(t, map[int]int64{0: 1, 1: 1}, topic.Committed())
+}
+
+func testLeavesBatchOnShutdown(t *testing.T, topic *stream.MemoryTopic, w *writerMock) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		produceFrame(t, topic, fmt.Sprint(i))
+	}
+	r := topic.NewReader()
+	w.respectCtx = true
+
+	// The pipeline is stopped once the last frame is processed,
+	// while the frames are waiting in the partial batch.
+	var processed int
+	proc := processorFunc(func(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+		processed++
+		if processed == 2 {
+			cancel()
+		}
+		return &api.ConvertedBlob{FrameId: frame.FrameId}, nil
+	})
+
+	log, _ := logger.NewNullLogger()
+	sl := &sleeperMock{}
+	p, err := NewPipeline(
+		Config{BatchSize: 10, BatchTimeout: time.Hour},
+		r, w, nil, proc, sl.new, &reporterMock{}, log,
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Run(ctx))
+	require.Equal(t, 0, w.writeCount)
+	require.Equal(t, 0, sl.sleepCount)
+	require.Equal(t, map[int]int64{0: 0, 1: 0}, topic.Committed())
+}
+
+func TestPipelineHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic := stream.NewMemoryTopic("frames", 1)
+	value, err := (&api.InputFrame{FrameId: "frame_1"}).Marshal()
+	require.NoError(t, err)
+
+	topic.Produce(kafka.Message{
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "correlation-id", Value: []byte("c1")},
+			{Key: "traceparent", Value: []byte("00-t1-s1-01")},
+			{Key: HeaderPipelineID, Value: []byte("upstream")},
+		},
+	})
+	r := topic.NewReader()
+
+	var written []kafka.Message
+	w := &writerMock{}
+	w.writeHook = func(msgs ...kafka.Message) { written = append(written, msgs...) }
+	r.CommitHook = func(ctx context.Context, msgs ...kafka.Message) error {
+		cancel()
+		return nil
+	}
+
+	p := newBatchingPipeline(t, Config{ConverterKind: "gzip"}, r, w)
+	require.NoError(t, p.Run(ctx))
+
+	require.Len(t, written, 1)
+	m := written[0]
+
+	require.Equal(t, []byte("frame_1"), m.Key)
+	require.Equal(t, "c1", headerValue(m, "correlation-id"))
+	require.Equal(t, "00-t1-s1-01", headerValue(m, "traceparent"))
+	require.Equal(t, p.id, headerValue(m, HeaderPipelineID))
+	require.Equal(t, "frames", headerValue(m, HeaderSourceTopic))
+	require.Equal(t, "0", headerValue(m, HeaderSourcePartition))
+	require.Equal(t, "0", headerValue(m, HeaderSourceOffset))
+	require.Equal(t, "gzip", headerValue(m, HeaderConverterKind))
+
+	duration, err := strconv.ParseFloat(headerValue(m, HeaderProcessingDuration), 64)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, duration, 0.0)
+
+	var pipelineIDs int
+	for _, h := range m.Headers {
+		if h.Key == HeaderPipelineID {
+			pipelineIDs++
+		}
+	}
+	require.Equal(t, 1, pipelineIDs)
+}
+
+func TestPipelineTracing(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic := stream.NewMemoryTopic("frames", 1)
+	value, err := (&api.InputFrame{FrameId: "frame_1"}).Marshal()
+	require.NoError(t, err)
+
+	traceID := "0af7651916cd43dd8448eb211c80319c"
+	topic.Produce(kafka.Message{
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "traceparent", Value: []byte("00-" + traceID + "-b7ad6b7169203331-01")},
+		},
+	})
+	r := topic.NewReader()
+
+	var written []kafka.Message
+	w := &writerMock{}
+	w.writeHook = func(msgs ...kafka.Message) { written = append(written, msgs...) }
+	r.CommitHook = func(ctx context.Context, msgs ...kafka.Message) error {
+		cancel()
+		return nil
+	}
+
+	var processed trace.SpanContext
+	proc := processorFunc(func(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+		processed = trace.SpanContextFromContext(ctx)
+		return &api.ConvertedBlob{FrameId: frame.FrameId}, nil
+	})
+
+	log, _ := logger.NewNullLogger()
+	p, err := NewPipeline(Config{BatchSize: 1}, r, w, nil, proc, (&sleeperMock{}).new, &reporterMock{}, log)
+	require.NoError(t, err)
+	require.NoError(t, p.Run(ctx))
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		spans[s.Name()] = s
+	}
+	require.Contains(t, spans, "pipeline.fetch")
+	require.Contains(t, spans, "pipeline.handle")
+	require.Contains(t, spans, "pipeline.write")
+	require.Contains(t, spans, "pipeline.commit")
+
+	// The message handling continues the trace of the upstream producer.
+	handle := spans["pipeline.handle"]
+	require.Equal(t, traceID, handle.SpanContext().TraceID().String())
+	require.Equal(t, "b7ad6b7169203331", handle.Parent().SpanID().String())
+	require.Equal(t, traceID, spans["pipeline.fetch"].SpanContext().TraceID().String())
+	require.Equal(t, handle.SpanContext().SpanID(), processed.SpanID())
+
+	// The batch spans are linked to the spans of the handled messages.
+	for _, name := range []string{"pipeline.write", "pipeline.commit"} {
+		links := spans[name].Links()
+		require.Len(t, links, 1, name)
+		require.Equal(t, handle.SpanContext().SpanID(), links[0].SpanContext.SpanID(), name)
+	}
+
+	// The converted blob message continues the trace of the message handling.
+	require.Len(t, written, 1)
+	require.Equal(t,
+		"00-"+traceID+"-"+handle.SpanContext().SpanID().String()+"-01",
+		headerValue(written[0], "traceparent"))
+}
+
+// produceFrame produces the message with the data frame to the topic.
+func produceFrame(t *testing.T, topic *stream.MemoryTopic, frameID string) {
+	value, err := (&api.InputFrame{FrameId: frameID}).Marshal()
+	require.NoError(t, err)
+
+	topic.Produce(kafka.Message{Value: value})
+}
+
+// blockingProcessorMock blocks the processing of each frame
+// until the frame is released.
+type blockingProcessorMock struct {
+	started  chan string
+	released map[string]chan struct{}
+}
+
+func (b *blockingProcessorMock) Process(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+	b.started <- frame.FrameId
+	<-b.released[frame.FrameId]
+	return &api.ConvertedBlob{FrameId: frame.FrameId}, nil
+}
+
+func TestPipelineStopsSleepingOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic := stream.NewMemoryTopic("frames", 1)
+	for i := 0; i < 4; i++ {
+		value, err := (&api.InputFrame{FrameId: fmt.Sprintf("frame_%d", i)}).Marshal()
+		require.NoError(t, err)
+		topic.Produce(kafka.Message{Value: value})
+	}
+
+	var mu sync.Mutex
+	var processed int
+	proc := processorFunc(func(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		processed++
+		if processed == 4 {
+			cancel()
+		}
+		return nil, failure.Transient(errors.New("storage is not available"))
+	})
+
+	// Each worker sleeps with its own sleeper, and the sleeps end on shutdown.
+	var sleepers int
+	newSleeper := func() Sleeper {
+		mu.Lock()
+		defer mu.Unlock()
+		sleepers++
+		return sleeper.NewExponentialSleeper(time.Hour, time.Hour)
+	}
+
+	log, _ := logger.NewNullLogger()
+	p, err := NewPipeline(
+		Config{Concurrency: 4},
+		topic.NewReader(), &writerMock{}, nil, proc, newSleeper, &reporterMock{}, log,
+	)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline is sleeping after shutdown")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.GreaterOrEqual(t, processed, 4)
+	require.GreaterOrEqual(t, sleepers, 5)
+}
+
+func TestPipelineDrain(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		r *readerMock,
+		w *writerMock,
+		pipeline func(p Processor) *Pipeline,
+	){
+		"finishes in-flight frame on shutdown":        testDrainsInFlightFrame,
+		"abandons in-flight frame after grace period": testAbandonsAfterGracePeriod,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			reader := &readerMock{respectCtx: true}
+			writer := &writerMock{respectCtx: true}
+			log, _ := logger.NewNullLogger()
+
+			fn(t, reader, writer, func(p Processor) *Pipeline {
+				pipeline, err := NewPipeline(
+					Config{GracePeriod: 50 * time.Millisecond},
+					reader,
+					writer,
+					nil,
+					p,
+					(&sleeperMock{}).new,
+					&reporterMock{},
+					log,
+				)
+				require.NoError(t, err)
+				return pipeline
+			})
+		})
+	}
+}
+
+// processorFunc is an adapter to use a function as a processor.
+type processorFunc func(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error)
+
+func (f processorFunc) Process(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+	return f(ctx, frame)
+}
+
+func testDrainsInFlightFrame(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	pipeline func(p Processor) *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var processErr error
+	p := pipeline(processorFunc(func(pctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+		// The pipeline is stopped in the middle of the frame processing.
+		cancel()
+		processErr = pctx.Err()
+		return &api.ConvertedBlob{}, nil
+	}))
+
+	require.NoError(t, p.Run(ctx))
+	require.NoError(t, processErr)
+
+	require.Equal(t, 1, r.fetchCount)
+	require.Equal(t, 1, w.writeCount)
+	require.Equal(t, 1, r.commitCount)
+}
+
+func testAbandonsAfterGracePeriod(
+	t *testing.T,
+	r *readerMock,
+	w *writerMock,
+	pipeline func(p Processor) *Pipeline,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := pipeline(processorFunc(func(pctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+		cancel()
+		<-pctx.Done()
+		return nil, pctx.Err()
+	}))
+
+	started := time.Now()
+	require.NoError(t, p.Run(ctx))
+	require.GreaterOrEqual(t, int64(time.Since(started)), int64(50*time.Millisecond))
+
+	require.Equal(t, 1, r.fetchCount)
+	require.Equal(t, 0, w.writeCount)
+	require.Equal(t, 0, r.commitCount)
+}
+
+func TestPipelineEndToEnd(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		st *storage.MemoryStorage,
+		input *stream.MemoryTopic,
+		output *stream.MemoryTopic,
+		deadLetter *stream.MemoryTopic,
+		run func(),
+	){
+		"converts and commits the frames":        testEndToEndConverts,
+		"dead-letters the missing frames":        testEndToEndDeadLetters,
+		"recovers from transient storage errors": testEndToEndRecovers,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			st := storage.NewMemoryStorage()
+			input := stream.NewMemoryTopic("frames", 2)
+			output := stream.NewMemoryTopic("blobs", 1)
+			deadLetter := stream.NewMemoryTopic("dead-letter", 1)
+			log, _ := logger.NewNullLogger()
+
+			converter, err := processor.NewConverter(processor.ConverterConfig{Kind: processor.ConverterIdentity}, log)
+			require.NoError(t, err)
+
+			proc, err := processor.NewProcessor(
+				processor.ProcessorConfig{DestinationBucket: "blobs"},
+				converter,
+				processor.Storages{api.Location_MINIO: st},
+				log,
+			)
+			require.NoError(t, err)
+
+			run := func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				reader := input.NewReader()
+				pipeline, err := NewPipeline(
+					Config{Concurrency: 2, BatchSize: 2, BatchTimeout: time.Millisecond},
+					reader,
+					output.NewWriter(),
+					deadLetter.NewWriter(),
+					proc,
+					(&sleeperMock{}).new,
+					&reporterMock{},
+					log,
+				)
+				require.NoError(t, err)
+
+				done := make(chan error)
+				go func() { done <- pipeline.Run(ctx) }()
+
+				require.Eventually(t, func() bool { return input.Lag() == 0 }, time.Second, time.Millisecond)
+				cancel()
+				require.NoError(t, <-done)
+			}
+
+			fn(t, st, input, output, deadLetter, run)
+		})
+	}
+}
+
+// produceStoredFrame stores the data frame and produces the message with the frame to the topic.
+func produceStoredFrame(t *testing.T, st *storage.MemoryStorage, topic *stream.MemoryTopic, frameID string) {
+	st.Put("frames", frameID, storage.MemoryObject{Bytes: []byte(frameID)})
+
+	value, err := (&api.InputFrame{
+		FrameId:       frameID,
+		FrameLocation: &api.Location{Bucket: "frames", ObjectName: frameID},
+	}).Marshal()
+	require.NoError(t, err)
+
+	topic.Produce(kafka.Message{Key: []byte(frameID), Value: value})
+}
+
+func testEndToEndConverts(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	input *stream.MemoryTopic,
+	output *stream.MemoryTopic,
+	deadLetter *stream.MemoryTopic,
+	run func(),
+) {
+	for i := 0; i < 5; i++ {
+		produceStoredFrame(t, st, input, fmt.Sprint("frame-", i))
+	}
+
+	run()
+
+	require.Len(t, output.Messages(), 5)
+	require.Empty(t, deadLetter.Messages())
+	require.Len(t, st.Objects("blobs"), 5)
+
+	for _, m := range output.Messages() {
+		var blob api.ConvertedBlob
+		require.NoError(t, blob.Unmarshal(m.Value))
+
+		object, ok := st.Object(blob.ConvertedLocation.Bucket, blob.ConvertedLocation.ObjectName)
+		require.True(t, ok)
+		require.Equal(t, []byte(blob.FrameId), object.Bytes)
+	}
+}
+
+func testEndToEndDeadLetters(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	input *stream.MemoryTopic,
+	output *stream.MemoryTopic,
+	deadLetter *stream.MemoryTopic,
+	run func(),
+) {
+	produceStoredFrame(t, st, input, "frame-0")
+	produceStoredFrame(t, st, input, "frame-1")
+
+	value, err := (&api.InputFrame{
+		FrameId:       "missing",
+		FrameLocation: &api.Location{Bucket: "frames", ObjectName: "missing"},
+	}).Marshal()
+	require.NoError(t, err)
+	input.Produce(kafka.Message{Value: value})
+
+	run()
+
+	require.Len(t, output.Messages(), 2)
+	require.Len(t, deadLetter.Messages(), 1)
+	require.Equal(t, StageProcess, headerValue(deadLetter.Messages()[0], HeaderFailureStage))
+}
+
+func testEndToEndRecovers(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	input *stream.MemoryTopic,
+	output *stream.MemoryTopic,
+	deadLetter *stream.MemoryTopic,
+	run func(),
+) {
+	var (
+		mu       sync.Mutex
+		failures int
+	)
+	st.RetrieveHook = func(ctx context.Context, bucket string, objectName string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failures < 2 {
+			failures++
+			return failure.Transient(errors.New("connection refused"))
+		}
+		return nil
+	}
+
+	produceStoredFrame(t, st, input, "frame-0")
+
+	run()
+
+	require.Len(t, output.Messages(), 1)
+	require.Empty(t, deadLetter.Messages())
+}
@@ -0,0 +1,301 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+)
+
+var (
+	// ErrNoPipelinesProvided happens when no pipelines are provided to the supervisor.
+	ErrNoPipelinesProvided = errors.New("no pipelines provided")
+
+	// ErrTooManyPipelines happens when the number of pipelines exceeds the limit.
+	ErrTooManyPipelines = errors.New("too many pipelines")
+
+	// ErrDrainTimeout happens when the pipelines have not stopped
+	// within the drain timeout.
+	ErrDrainTimeout = errors.New("pipelines have not stopped within the drain timeout")
+)
+
+const (
+	// defaultRestartBackoff is the initial restart backoff,
+	// if it is not configured.
+	defaultRestartBackoff = time.Second
+
+	// defaultMaxRestartBackoff is the maximum restart backoff,
+	// if it is not configured.
+	defaultMaxRestartBackoff = time.Minute
+)
+
+// Runner is a long-running routine, that runs until the context is canceled.
+type Runner interface {
+	ID() string
+	Run(ctx context.Context) error
+}
+
+// RunnerFactory creates a new runner each time the supervised pipeline is started.
+// The failed runner is not reused, so the restarted pipeline gets the new streams,
+// and the messages that were not committed by the failed runner are redelivered.
+//
+// If the runner implements io.Closer, it is closed once it is stopped.
+type RunnerFactory func() (Runner, error)
+
+// State is the state of a supervised pipeline.
+type State int
+
+const (
+	// StateStarting is the state of the pipeline that is not started yet.
+	StateStarting State = iota
+
+	// StateRunning is the state of the running pipeline.
+	StateRunning
+
+	// StateRestarting is the state of the failed pipeline,
+	// that waits for the restart.
+	StateRestarting
+
+	// StateStopped is the state of the stopped pipeline.
+	StateStopped
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateRestarting:
+		return "restarting"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// Status is the status of a supervised pipeline.
+type Status struct {
+	// ID of the current runner of the pipeline,
+	// it is empty until the pipeline is started.
+	ID       string
+	State    State
+	Restarts int
+	LastErr  error
+}
+
+// SupervisorConfig defines how the supervisor restarts and stops the pipelines.
+type SupervisorConfig struct {
+	// Initial backoff before the failed pipeline is restarted.
+	// The backoff is doubled on each consecutive failure.
+	RestartBackoff time.Duration
+
+	// Maximum backoff before the failed pipeline is restarted.
+	// The backoff is reset if the pipeline has been running longer than that.
+	MaxRestartBackoff time.Duration
+
+	// Time given to the pipelines to stop, once the supervisor is stopped.
+	// The supervisor waits for the pipelines indefinitely if not set.
+	DrainTimeout time.Duration
+}
+
+// supervised is a pipeline that is run by the supervisor.
+type supervised struct {
+	factory RunnerFactory
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Supervisor runs a set of pipelines, restarting the failed ones.
+type Supervisor struct {
+	config    SupervisorConfig
+	pipelines []*supervised
+
+	log logger.Log
+}
+
+// NewSupervisor creates a new supervisor of the pipelines,
+// that are created by the given factories.
+func NewSupervisor(config SupervisorConfig, pipelines []RunnerFactory, log logger.Log) (*Supervisor, error) {
+	if len(pipelines) == 0 {
+		return nil, ErrNoPipelinesProvided
+	}
+
+	if len(pipelines) > maxPipelines {
+		return nil, ErrTooManyPipelines
+	}
+
+	if config.RestartBackoff <= 0 {
+		config.RestartBackoff = defaultRestartBackoff
+	}
+
+	if config.MaxRestartBackoff < config.RestartBackoff {
+		config.MaxRestartBackoff = defaultMaxRestartBackoff
+		if config.MaxRestartBackoff < config.RestartBackoff {
+			config.MaxRestartBackoff = config.RestartBackoff
+		}
+	}
+
+	s := &Supervisor{
+		config: config,
+		log:    log.WithField(logger.FieldPackage, "pipeline"),
+	}
+
+	for _, f := range pipelines {
+		s.pipelines = append(s.pipelines, &supervised{
+			factory: f,
+			status:  Status{State: StateStarting},
+		})
+	}
+
+	return s, nil
+}
+
+// Run runs the pipelines until the context is canceled.
+// The pipelines that fail are restarted with the exponential backoff.
+//
+// Once the context is canceled, Run waits for the pipelines to stop.
+// It returns ErrDrainTimeout if the pipelines have not stopped
+// within the drain timeout.
+func (s *Supervisor) Run(ctx context.Context) error {
+	log := s.log.WithField(logger.FieldFunction, "Supervisor.Run")
+	log.Infof("Starting %d pipelines.", len(s.pipelines))
+
+	var wg sync.WaitGroup
+	for _, p := range s.pipelines {
+		wg.Add(1)
+		go func(p *supervised) {
+			defer wg.Done()
+			s.supervise(ctx, p)
+		}(p)
+	}
+
+	<-ctx.Done()
+	log.Info("Stopping the pipelines.")
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	var timeout <-chan time.Time
+	if s.config.DrainTimeout > 0 {
+		timer := time.NewTimer(s.config.DrainTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-stopped:
+		log.Info("All pipelines have been stopped.")
+		return nil
+	case <-timeout:
+		log.Error(ErrDrainTimeout, "Pipelines have not stopped within the drain timeout.")
+		return ErrDrainTimeout
+	}
+}
+
+// Statuses returns the current statuses of the supervised pipelines.
+func (s *Supervisor) Statuses() []Status {
+	statuses := make([]Status, 0, len(s.pipelines))
+	for _, p := range s.pipelines {
+		p.mu.Lock()
+		statuses = append(statuses, p.status)
+		p.mu.Unlock()
+	}
+	return statuses
+}
+
+// supervise runs the pipeline until the context is canceled,
+// restarting the pipeline with a new runner if it fails.
+func (s *Supervisor) supervise(ctx context.Context, p *supervised) {
+	log := s.log.WithField(logger.FieldFunction, "Supervisor.supervise")
+
+	backoff := s.config.RestartBackoff
+	for {
+		started := time.Now()
+		err := s.run(ctx, p, log)
+
+		if ctx.Err() != nil || err == nil {
+			p.setState(StateStopped, err)
+			return
+		}
+
+		if time.Since(started) > s.config.MaxRestartBackoff {
+			backoff = s.config.RestartBackoff
+		}
+
+		p.setState(StateRestarting, err)
+		log.WithField("pipeline_id", p.id()).
+			Errorf(err, "Pipeline has failed. Restarting the pipeline in %s.", backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			p.setState(StateStopped, err)
+			return
+		case <-timer.C:
+		}
+
+		p.mu.Lock()
+		p.status.Restarts += 1
+		p.mu.Unlock()
+
+		backoff *= 2
+		if backoff > s.config.MaxRestartBackoff {
+			backoff = s.config.MaxRestartBackoff
+		}
+	}
+}
+
+// run creates a new runner of the pipeline and runs it until it is stopped.
+// The runner is closed once it is stopped, if it implements io.Closer.
+func (s *Supervisor) run(ctx context.Context, p *supervised, log logger.Log) error {
+	runner, err := p.factory()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.status.ID = runner.ID()
+	p.mu.Unlock()
+
+	if closer, ok := runner.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.WithField("pipeline_id", runner.ID()).Error(err, "Failed to close the pipeline.")
+			}
+		}()
+	}
+
+	p.setState(StateRunning, nil)
+	return runner.Run(ctx)
+}
+
+// setState updates the state of the supervised pipeline.
+// The last error is updated only if the error is not nil.
+func (p *supervised) setState(state State, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.State = state
+	if err != nil {
+		p.status.LastErr = err
+	}
+}
+
+// id returns the id of the current runner of the supervised pipeline.
+func (p *supervised) id() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.status.ID
+}
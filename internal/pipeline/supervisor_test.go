@@ -0,0 +1,265 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/stream"
+)
+
+func TestSupervisorCreation(t *testing.T) {
+	log, _ := logger.NewNullLogger()
+
+	supervisor, err := NewSupervisor(SupervisorConfig{}, nil, log)
+	require.Nil(t, supervisor)
+	require.Equal(t, ErrNoPipelinesProvided, err)
+
+	factories := make([]RunnerFactory, maxPipelines+1)
+	for i := range factories {
+		factories[i] = (&runnerMock{id: fmt.Sprint(i)}).create
+	}
+
+	supervisor, err = NewSupervisor(SupervisorConfig{}, factories, log)
+	require.Nil(t, supervisor)
+	require.Equal(t, ErrTooManyPipelines, err)
+}
+
+func TestSupervisorFlow(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		r *runnerMock,
+		s *Supervisor,
+	){
+		"restarts failed pipelines":           testRestartsFailedPipelines,
+		"restarts failed runner creation":     testRestartsFailedCreation,
+		"stops pipelines on canceled context": testStopsPipelines,
+		"fails on drain timeout":              testFailsOnDrainTimeout,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			runner := &runnerMock{id: "p_1"}
+			log, _ := logger.NewNullLogger()
+
+			supervisor, err := NewSupervisor(
+				SupervisorConfig{
+					RestartBackoff:    time.Millisecond,
+					MaxRestartBackoff: 4 * time.Millisecond,
+					DrainTimeout:      50 * time.Millisecond,
+				},
+				[]RunnerFactory{runner.create},
+				log,
+			)
+			require.NoError(t, err)
+
+			fn(t, runner, supervisor)
+		})
+	}
+}
+
+type runnerMock struct {
+	id string
+
+	mu             sync.Mutex
+	createCount    int
+	createFailures int
+	closeCount     int
+	runCount       int
+	failures       int
+	ignore         chan struct{}
+	running        chan struct{}
+}
+
+func (r *runnerMock) create() (Runner, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.createCount++
+	if r.createCount <= r.createFailures {
+		return nil, fmt.Errorf("creation failure %d", r.createCount)
+	}
+	return r, nil
+}
+
+func (r *runnerMock) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeCount++
+	return nil
+}
+
+func (r *runnerMock) ID() string {
+	return r.id
+}
+
+func (r *runnerMock) Run(ctx context.Context) error {
+	r.mu.Lock()
+	r.runCount++
+	fail := r.runCount <= r.failures
+	ignore := r.ignore
+	running := r.running
+	r.mu.Unlock()
+
+	if fail {
+		return fmt.Errorf("failure %d", r.runCount)
+	}
+
+	if running != nil {
+		close(running)
+	}
+
+	if ignore != nil {
+		// The context is ignored until the runner is released.
+		<-ignore
+		return nil
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func testRestartsFailedPipelines(t *testing.T, r *runnerMock, s *Supervisor) {
+	r.failures = 3
+	r.running = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	<-r.running
+
+	statuses := s.Statuses()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "p_1", statuses[0].ID)
+	require.Equal(t, StateRunning, statuses[0].State)
+	require.Equal(t, 3, statuses[0].Restarts)
+	require.EqualError(t, statuses[0].LastErr, "failure 3")
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, StateStopped, s.Statuses()[0].State)
+	require.Equal(t, 4, r.createCount)
+	require.Equal(t, 4, r.closeCount)
+}
+
+func testRestartsFailedCreation(t *testing.T, r *runnerMock, s *Supervisor) {
+	r.createFailures = 2
+	r.running = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	<-r.running
+
+	statuses := s.Statuses()
+	require.Equal(t, "p_1", statuses[0].ID)
+	require.Equal(t, StateRunning, statuses[0].State)
+	require.Equal(t, 2, statuses[0].Restarts)
+	require.EqualError(t, statuses[0].LastErr, "creation failure 2")
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, 1, r.runCount)
+}
+
+func testStopsPipelines(t *testing.T, r *runnerMock, s *Supervisor) {
+	r.running = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	<-r.running
+	cancel()
+
+	require.NoError(t, <-done)
+	require.Equal(t, 1, r.runCount)
+	require.Equal(t, 1, r.closeCount)
+	require.Equal(t, StateStopped, s.Statuses()[0].State)
+	require.NoError(t, s.Statuses()[0].LastErr)
+}
+
+func testFailsOnDrainTimeout(t *testing.T, r *runnerMock, s *Supervisor) {
+	r.ignore = make(chan struct{})
+	r.running = make(chan struct{})
+	defer close(r.ignore)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	<-r.running
+	cancel()
+
+	require.Equal(t, ErrDrainTimeout, <-done)
+	require.Equal(t, StateRunning, s.Statuses()[0].State)
+}
+
+func TestSupervisorRestartsFromCommittedOffsets(t *testing.T) {
+	input := stream.NewMemoryTopic("frames", 1)
+	output := stream.NewMemoryTopic("blobs", 1)
+	log, _ := logger.NewNullLogger()
+
+	produceFrame(t, input, "a")
+	produceFrame(t, input, "b")
+
+	// The first batch fails to be written, so the first pipeline fails.
+	var writes int
+	writer := output.NewWriter()
+	writer.WriteHook = func(ctx context.Context, msgs ...kafka.Message) error {
+		writes++
+		if writes <= retryWriteCount {
+			return errors.New("broker is not available")
+		}
+		return nil
+	}
+
+	factory := func() (Runner, error) {
+		return NewPipeline(
+			Config{BatchSize: 1},
+			input.NewReader(),
+			writer,
+			nil,
+			processorFunc(func(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+				return &api.ConvertedBlob{FrameId: frame.FrameId}, nil
+			}),
+			(&sleeperMock{}).new,
+			&reporterMock{},
+			log,
+		)
+	}
+
+	supervisor, err := NewSupervisor(
+		SupervisorConfig{RestartBackoff: time.Millisecond},
+		[]RunnerFactory{factory},
+		log,
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- supervisor.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return input.Lag() == 0 }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	var frames []string
+	for _, m := range output.Messages() {
+		var blob api.ConvertedBlob
+		require.NoError(t, blob.Unmarshal(m.Value))
+		frames = append(frames, blob.FrameId)
+	}
+	require.Equal(t, []string{"a", "b"}, frames)
+	require.Equal(t, map[int]int64{0: 2}, input.Committed())
+	require.Equal(t, 1, supervisor.Statuses()[0].Restarts)
+}
@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"errors"
+	"net/textproto"
+	"net/url"
+	"regexp"
+)
+
+const (
+	// MetadataConverterAttributes is the metadata key of the attributes,
+	// that are added or changed by the converter. The attributes are
+	// URL-encoded, so any attribute name and value could be stored.
+	MetadataConverterAttributes = "Converter-Attributes"
+
+	// metadataAttributePrefix is the prefix of the metadata keys
+	// of the data frame attributes.
+	metadataAttributePrefix = "Attribute-"
+)
+
+var (
+	// ErrInvalidAttribute happens when the attribute name
+	// could not be stored as the object metadata key.
+	ErrInvalidAttribute = errors.New("attribute name should have only letters, digits and dashes")
+
+	attributeName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// copyAttributes returns a copy of the attributes, or nil if there are none.
+func copyAttributes(values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
+
+// validateAttributes returns an error if any of the attribute names
+// could not be stored as the object metadata key.
+func validateAttributes(names []string) error {
+	for _, name := range names {
+		if !attributeName.MatchString(name) {
+			return ErrInvalidAttribute
+		}
+	}
+	return nil
+}
+
+// attributeKey returns the metadata key of the attribute.
+// The key is canonical, the same way the object storage returns it.
+func attributeKey(name string) string {
+	return textproto.CanonicalMIMEHeaderKey(metadataAttributePrefix + name)
+}
+
+// storeAttributes adds the selected attributes to the object metadata.
+func storeAttributes(metadata map[string]string, names []string, values map[string]string) {
+	for _, name := range names {
+		if value, ok := values[name]; ok {
+			metadata[attributeKey(name)] = value
+		}
+	}
+}
+
+// storeConverterAttributes adds the attributes, that are added or changed
+// by the converter, to the object metadata, so they could be restored
+// without the conversion (see loadAttributes).
+func storeConverterAttributes(metadata map[string]string, frame map[string]string, values map[string]string) {
+	added := url.Values{}
+	for name, value := range values {
+		if frameValue, ok := frame[name]; !ok || frameValue != value {
+			added.Set(name, value)
+		}
+	}
+	if len(added) > 0 {
+		metadata[MetadataConverterAttributes] = added.Encode()
+	}
+}
+
+// loadAttributes returns the attributes of the stored blob, that are
+// the data frame attributes and the attributes added by the converter.
+// It returns an error if the converter attributes could not be decoded.
+func loadAttributes(frame map[string]string, metadata map[string]string) (map[string]string, error) {
+	added, err := url.ParseQuery(metadata[MetadataConverterAttributes])
+	if err != nil {
+		return nil, err
+	}
+
+	values := copyAttributes(frame)
+	for name := range added {
+		if values == nil {
+			values = make(map[string]string)
+		}
+		values[name] = added.Get(name)
+	}
+	return values, nil
+}
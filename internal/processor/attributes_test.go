@@ -0,0 +1,178 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/internal/validation"
+	"github.com/weak-head/data-pipe/pkg/convert"
+)
+
+func TestAttributes(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		st *storage.MemoryStorage,
+		frame *api.InputFrame,
+		configure testProcessorOption,
+	){
+		"passes the attributes through":         testPassesAttributesThrough,
+		"passes the streamed frame attributes":  testPassesStreamedAttributes,
+		"restores attributes of streamed blob":  testRestoresStreamedAttributes,
+		"restores attributes of converted blob": testRestoresAttributes,
+		"restores the converter attributes":     testRestoresConverterAttributes,
+		"converts if attributes are corrupt":    testConvertsOnCorruptAttributes,
+		"validates the metadata attributes":     testValidatesMetadataAttributes,
+		"encodes the attributes":                testEncodesAttributes,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			st := storage.NewMemoryStorage()
+			st.Put("frames", "frame", storage.MemoryObject{Bytes: []byte("frame")})
+
+			frame := newFrame(sha256Hex("frame"))
+			frame.Attributes = map[string]string{
+				"tenant-id":      "tenant",
+				"correlation-id": "correlation",
+			}
+
+			fn(t, st, frame, withConfig(ProcessorConfig{
+				Idempotent:         true,
+				MetadataAttributes: []string{"tenant-id", "source"},
+			}))
+		})
+	}
+}
+
+// sourceConverter adds the source attribute to the converted blob.
+var sourceConverter = converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+	tenant, _ := convert.Attribute(ctx, "tenant-id")
+	convert.SetAttribute(ctx, "source", "camera-of-"+tenant)
+	return from, nil
+})
+
+// sourceStreamConverter adds the source attribute to the streamed blob.
+type sourceStreamConverter struct {
+	identityConverter
+}
+
+func (c *sourceStreamConverter) ConvertStream(ctx context.Context, from io.Reader, to io.Writer) error {
+	convert.SetAttribute(ctx, "source", "stream")
+	return c.identityConverter.ConvertStream(ctx, from, to)
+}
+
+func testPassesAttributesThrough(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	blob, object := processStored(t, newTestProcessor(t, st, configure, withConverter(sourceConverter)), st, frame)
+
+	require.Equal(t, map[string]string{
+		"tenant-id":      "tenant",
+		"correlation-id": "correlation",
+		"source":         "camera-of-tenant",
+	}, blob.Attributes)
+
+	require.Equal(t, "tenant", object.Metadata["Attribute-Tenant-Id"])
+	require.Equal(t, "camera-of-tenant", object.Metadata["Attribute-Source"])
+	require.NotContains(t, object.Metadata, "Attribute-Correlation-Id")
+}
+
+func testPassesStreamedAttributes(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	blob, object := processStored(t, newTestProcessor(t, st, configure, withConverter(&sourceStreamConverter{})), st, frame)
+
+	require.Equal(t, "stream", blob.Attributes["source"])
+	require.Equal(t, "correlation", blob.Attributes["correlation-id"])
+
+	require.Equal(t, "tenant", object.Metadata["Attribute-Tenant-Id"])
+	require.Equal(t, "stream", object.Metadata["Attribute-Source"])
+	require.NotContains(t, object.Metadata, "Attribute-Correlation-Id")
+}
+
+func testRestoresStreamedAttributes(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	p := newTestProcessor(t, st, configure, withConverter(&sourceStreamConverter{}))
+
+	first, _ := processStored(t, p, st, frame)
+
+	st.RetrieveHook = func(ctx context.Context, bucket string, objectName string) error {
+		return errors.New("data frame should not be retrieved")
+	}
+
+	second, _ := processStored(t, p, st, frame)
+	require.Equal(t, "stream", second.Attributes["source"])
+	require.Equal(t, first.Attributes, second.Attributes)
+}
+
+func testRestoresAttributes(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	p := newTestProcessor(t, st, configure, withConverter(sourceConverter))
+
+	first, _ := processStored(t, p, st, frame)
+
+	st.RetrieveHook = func(ctx context.Context, bucket string, objectName string) error {
+		return errors.New("data frame should not be retrieved")
+	}
+
+	second, _ := processStored(t, p, st, frame)
+	require.Equal(t, first.Attributes, second.Attributes)
+}
+
+func testRestoresConverterAttributes(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	formatConverter := converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+		convert.SetAttribute(ctx, "Frame Format", "raw & v2")
+		convert.SetAttribute(ctx, "tenant-id", "another tenant")
+		return from, nil
+	})
+	p := newTestProcessor(t, st, configure, withConverter(formatConverter))
+
+	first, object := processStored(t, p, st, frame)
+	require.Equal(t, "Frame+Format=raw+%26+v2&tenant-id=another+tenant", object.Metadata[MetadataConverterAttributes])
+
+	st.RetrieveHook = func(ctx context.Context, bucket string, objectName string) error {
+		return errors.New("data frame should not be retrieved")
+	}
+
+	second, _ := processStored(t, p, st, frame)
+	require.Equal(t, map[string]string{
+		"tenant-id":      "another tenant",
+		"correlation-id": "correlation",
+		"Frame Format":   "raw & v2",
+	}, second.Attributes)
+	require.Equal(t, first.Attributes, second.Attributes)
+}
+
+func testConvertsOnCorruptAttributes(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	p := newTestProcessor(t, st, configure, withConverter(sourceConverter))
+
+	first, object := processStored(t, p, st, frame)
+	object.Metadata[MetadataConverterAttributes] = "source=%zz"
+	st.Put("blobs", first.ConvertedLocation.ObjectName, object)
+
+	second, object := processStored(t, p, st, frame)
+	require.Equal(t, first.Attributes, second.Attributes)
+	require.Equal(t, "source=camera-of-tenant", object.Metadata[MetadataConverterAttributes])
+}
+
+func testValidatesMetadataAttributes(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	config := ProcessorConfig{
+		DestinationBucket:  "blobs",
+		MetadataAttributes: []string{"tenant-id", "source system"},
+	}
+
+	var errs validation.Errors
+	require.True(t, errors.As(config.Validate(), &errs))
+	require.Len(t, errs, 1)
+	require.Equal(t, "metadataattributes", errs[0].Path)
+	require.True(t, errors.Is(errs[0].Err, ErrInvalidAttribute))
+}
+
+func testEncodesAttributes(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame, configure testProcessorOption) {
+	blob, _ := processStored(t, newTestProcessor(t, st, configure, withConverter(sourceConverter)), st, frame)
+
+	b, err := blob.Marshal()
+	require.NoError(t, err)
+
+	var decoded api.ConvertedBlob
+	require.NoError(t, decoded.Unmarshal(b))
+	require.Equal(t, blob.Attributes, decoded.Attributes)
+}
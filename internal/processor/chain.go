@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/pkg/convert"
+)
+
+const (
+	// ConverterChain is the kind of the converter
+	// that runs a sequence of converters.
+	ConverterChain = "chain"
+)
+
+var (
+	// ErrEmptyChain happens when the converter chain has no steps.
+	ErrEmptyChain = errors.New("converter chain has no steps")
+)
+
+// ChainConfig is the configuration section of the converter chain.
+type ChainConfig struct {
+	// Steps are the converters that are run in order,
+	// the output of each step is the input of the next one.
+	Steps []ConverterConfig
+}
+
+// ChainStep is a single step of the converter chain.
+type ChainStep struct {
+	// Kind of the step converter, used to report the step.
+	Kind string
+
+	Converter convert.Converter
+}
+
+// StepError is the failure of a single step of the converter chain.
+// The failure kind of the step error is preserved (see failure.KindOf).
+type StepError struct {
+	Step int
+	Kind string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %d (%s): %v", e.Step, e.Kind, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// StepReporter reports the converter chain steps,
+// that are labeled with the index of the step and the kind of its converter.
+//
+// ConverterStepFinished is reported with the duration of the succeeded step,
+// and ConverterStepFailed is reported once the step has failed.
+// The steps that are not run, because the context is done, are not reported.
+type StepReporter interface {
+	ConverterStepFinished(step string, converter string, duration time.Duration)
+	ConverterStepFailed(step string, converter string)
+}
+
+// nopStepReporter is the step reporter that reports nothing.
+type nopStepReporter struct{}
+
+func (nopStepReporter) ConverterStepFinished(step string, converter string, duration time.Duration) {}
+func (nopStepReporter) ConverterStepFailed(step string, converter string)                           {}
+
+// chainConverter runs a sequence of converters
+// and records the duration and failure of each step.
+type chainConverter struct {
+	steps    []ChainStep
+	reporter StepReporter
+
+	log logger.Log
+}
+
+// NewChainConverter creates a new converter that runs the steps in order.
+// It returns an error if the chain has no steps.
+func NewChainConverter(steps []ChainStep, log logger.Log) (*chainConverter, error) {
+	if len(steps) == 0 {
+		return nil, ErrEmptyChain
+	}
+
+	for i, step := range steps {
+		if step.Converter == nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i, step.Kind, ErrNoConverterProvided)
+		}
+	}
+
+	return &chainConverter{
+		steps:    steps,
+		reporter: nopStepReporter{},
+		log:      log.WithField(logger.FieldPackage, "processor"),
+	}, nil
+}
+
+// WithStepReporter returns the converter, that reports its chain steps
+// to the reporter, including the steps of the nested chains.
+// The converters that are not chains are returned as is.
+func WithStepReporter(converter convert.Converter, reporter StepReporter) convert.Converter {
+	chain, ok := converter.(*chainConverter)
+	if !ok {
+		return converter
+	}
+
+	steps := make([]ChainStep, 0, len(chain.steps))
+	for _, step := range chain.steps {
+		step.Converter = WithStepReporter(step.Converter, reporter)
+		steps = append(steps, step)
+	}
+
+	return &chainConverter{
+		steps:    steps,
+		reporter: reporter,
+		log:      chain.log,
+	}
+}
+
+func newChainConverterFromOptions(options convert.Options, log logger.Log) (convert.Converter, error) {
+	var config ChainConfig
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	steps := make([]ChainStep, 0, len(config.Steps))
+	for i, stepConfig := range config.Steps {
+		converter, err := NewConverter(stepConfig, log)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		steps = append(steps, ChainStep{Kind: stepConfig.Kind, Converter: converter})
+	}
+
+	return NewChainConverter(steps, log)
+}
+
+// Content returns the content of the last step.
+// The encoding of the step is applied on top of the content of the previous steps,
+// and the step that doesn't declare its content makes the content unknown.
+func (c *chainConverter) Content() convert.Content {
+	var content convert.Content
+	for _, step := range c.steps {
+		stepContent := convert.ContentOf(step.Converter)
+		switch {
+		case stepContent.Type == "" && stepContent.Encoding == "":
+			content = convert.Content{}
+		case stepContent.Type == "":
+			content.Encoding = stepContent.Encoding
+		default:
+			content = stepContent
+		}
+	}
+	return content
+}
+
+// Convert runs the steps of the chain in order.
+// The chain is stopped on the first failed step,
+// and the step failure is returned as StepError.
+// The duration and failure of each step are reported (see StepReporter).
+func (c *chainConverter) Convert(ctx context.Context, from []byte) (to []byte, err error) {
+	log := c.log.WithField(logger.FieldFunction, "chainConverter.Convert")
+
+	for i, step := range c.steps {
+		if err := ctx.Err(); err != nil {
+			return nil, &StepError{Step: i, Kind: step.Kind, Err: err}
+		}
+
+		started := time.Now()
+		to, err := step.Converter.Convert(ctx, from)
+		duration := time.Since(started)
+		fields := logger.Fields{
+			"step":      i,
+			"converter": step.Kind,
+			"duration":  duration,
+		}
+
+		if err != nil {
+			err = &StepError{Step: i, Kind: step.Kind, Err: err}
+			log.ErrorWithFields(err, fields, "Converter step has failed.")
+			c.reporter.ConverterStepFailed(strconv.Itoa(i), step.Kind)
+			return nil, err
+		}
+
+		log.DebugWithFields(fields, "Converter step has finished.")
+		c.reporter.ConverterStepFinished(strconv.Itoa(i), step.Kind, duration)
+		from = to
+	}
+
+	return from, nil
+}
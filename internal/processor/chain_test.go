@@ -0,0 +1,170 @@
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/pkg/convert"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestChainConverter(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"runs steps in order":                    testChainRunsStepsInOrder,
+		"is created from options":                testChainFromOptions,
+		"stops on failed step":                   testChainStopsOnFailedStep,
+		"fails to create without steps":          testChainFailsWithoutSteps,
+		"fails to create with unknown step":      testChainFailsOnUnknownStep,
+		"fails to create with missing converter": testChainFailsOnMissingConverter,
+		"reports the steps of nested chains":     testChainReportsSteps,
+		"returns other converters as is":         testChainReporterSkipsConverters,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+// stepReporterMock records the reported steps as "<step>:<converter>".
+type stepReporterMock struct {
+	finished []string
+	failed   []string
+}
+
+func (r *stepReporterMock) ConverterStepFinished(step string, converter string, duration time.Duration) {
+	r.finished = append(r.finished, step+":"+converter)
+}
+
+func (r *stepReporterMock) ConverterStepFailed(step string, converter string) {
+	r.failed = append(r.failed, step+":"+converter)
+}
+
+func appendStep(suffix string) ChainStep {
+	return ChainStep{
+		Kind: "append-" + suffix,
+		Converter: converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+			return append(append([]byte(nil), from...), suffix...), nil
+		}),
+	}
+}
+
+func testChainRunsStepsInOrder(t *testing.T) {
+	c, err := NewChainConverter([]ChainStep{appendStep("a"), appendStep("b"), appendStep("c")}, nullLogger())
+	require.NoError(t, err)
+
+	to, err := c.Convert(context.Background(), []byte("frame-"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("frame-abc"), to)
+}
+
+func testChainFromOptions(t *testing.T) {
+	c, err := NewConverter(ConverterConfig{
+		Kind: ConverterChain,
+		Options: convert.Options{
+			"steps": []interface{}{
+				map[string]interface{}{"kind": ConverterGunzip},
+				map[string]interface{}{"kind": ConverterIdentity},
+				map[string]interface{}{"kind": ConverterGzip, "options": map[string]interface{}{"level": 1}},
+			},
+		},
+	}, nullLogger())
+	require.NoError(t, err)
+
+	var frame bytes.Buffer
+	w := gzip.NewWriter(&frame)
+	_, err = w.Write([]byte("frame"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	to, err := c.Convert(context.Background(), frame.Bytes())
+	require.NoError(t, err)
+
+	r, err := gzip.NewReader(bytes.NewReader(to))
+	require.NoError(t, err)
+	from, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte("frame"), from)
+}
+
+func testChainStopsOnFailedStep(t *testing.T) {
+	log, hook := logger.NewNullLogger()
+
+	var lastCalled bool
+	c, err := NewChainConverter([]ChainStep{
+		appendStep("a"),
+		{Kind: ConverterGunzip, Converter: &gunzipConverter{}},
+		{Kind: "last", Converter: converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+			lastCalled = true
+			return from, nil
+		})},
+	}, log)
+	require.NoError(t, err)
+
+	_, err = c.Convert(context.Background(), []byte("not gzip"))
+	require.False(t, lastCalled)
+
+	var stepErr *StepError
+	require.True(t, errors.As(err, &stepErr))
+	require.Equal(t, 1, stepErr.Step)
+	require.Equal(t, ConverterGunzip, stepErr.Kind)
+	require.Equal(t, failure.KindCorrupt, failure.KindOf(err))
+
+	entry := hook.LastEntry()
+	require.Equal(t, "Converter step has failed.", entry.Message)
+	require.Equal(t, 1, entry.Data["step"])
+	require.Equal(t, ConverterGunzip, entry.Data["converter"])
+	require.IsType(t, time.Duration(0), entry.Data["duration"])
+}
+
+func testChainFailsWithoutSteps(t *testing.T) {
+	_, err := NewChainConverter(nil, nullLogger())
+	require.True(t, errors.Is(err, ErrEmptyChain))
+
+	_, err = NewConverter(ConverterConfig{Kind: ConverterChain}, nullLogger())
+	require.True(t, errors.Is(err, ErrEmptyChain))
+}
+
+func testChainFailsOnUnknownStep(t *testing.T) {
+	_, err := NewConverter(ConverterConfig{
+		Kind: ConverterChain,
+		Options: convert.Options{
+			"steps": []interface{}{map[string]interface{}{"kind": "unknown"}},
+		},
+	}, nullLogger())
+	require.True(t, errors.Is(err, ErrUnknownConverter))
+}
+
+func testChainFailsOnMissingConverter(t *testing.T) {
+	_, err := NewChainConverter([]ChainStep{{Kind: "missing"}}, nullLogger())
+	require.True(t, errors.Is(err, ErrNoConverterProvided))
+}
+
+func testChainReportsSteps(t *testing.T) {
+	nested, err := NewChainConverter([]ChainStep{appendStep("b"), {Kind: ConverterGunzip, Converter: &gunzipConverter{}}}, nullLogger())
+	require.NoError(t, err)
+	c, err := NewChainConverter([]ChainStep{appendStep("a"), {Kind: ConverterChain, Converter: nested}}, nullLogger())
+	require.NoError(t, err)
+
+	reporter := &stepReporterMock{}
+	_, err = WithStepReporter(c, reporter).Convert(context.Background(), []byte("frame"))
+	require.Error(t, err)
+
+	require.Equal(t, []string{"0:append-a", "0:append-b"}, reporter.finished)
+	require.Equal(t, []string{"1:gunzip", "1:chain"}, reporter.failed)
+
+	// The original chain doesn't report its steps.
+	_, err = c.Convert(context.Background(), []byte("frame"))
+	require.Error(t, err)
+	require.Len(t, reporter.finished, 2)
+}
+
+func testChainReporterSkipsConverters(t *testing.T) {
+	converter := &identityConverter{}
+	require.Same(t, converter, WithStepReporter(converter, &stepReporterMock{}))
+}
@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+const (
+	// MetadataChecksum is the metadata key of the converted blob checksum.
+	MetadataChecksum = "Checksum"
+
+	// MetadataSize is the metadata key of the converted blob size.
+	MetadataSize = "Size-Bytes"
+
+	// MetadataFrameChecksum is the metadata key of the checksum
+	// of the data frame, that the blob has been converted from.
+	MetadataFrameChecksum = "Frame-Checksum"
+
+	// MetadataFrameSize is the metadata key of the size
+	// of the data frame, that the blob has been converted from.
+	MetadataFrameSize = "Frame-Size-Bytes"
+)
+
+var (
+	// ErrChecksumMismatch happens when the checksum of the data frame
+	// doesn't match the expected checksum.
+	ErrChecksumMismatch = failure.Corrupt(errors.New("checksum mismatch"))
+
+	// ErrSizeMismatch happens when the size of the data frame
+	// doesn't match the expected size.
+	ErrSizeMismatch = failure.Corrupt(errors.New("size mismatch"))
+
+	// ErrNoChecksum happens when the checksum is required,
+	// but the data frame has no checksum.
+	ErrNoChecksum = failure.Corrupt(errors.New("data frame has no checksum"))
+)
+
+// digest is the hex-encoded SHA-256 checksum and the size of the content.
+type digest struct {
+	checksum string
+	size     int64
+}
+
+// digestOf returns the digest of the content.
+func digestOf(b []byte) digest {
+	sum := sha256.Sum256(b)
+	return digest{
+		checksum: hex.EncodeToString(sum[:]),
+		size:     int64(len(b)),
+	}
+}
+
+// verify returns an error if the digest doesn't match
+// the expected checksum or size of the data frame.
+// The checksum and size are verified only if they are set.
+func (d digest) verify(frame *api.InputFrame) error {
+	if frame.SizeBytes != 0 && frame.SizeBytes != d.size {
+		return fmt.Errorf("%w: expected %d bytes, got %d bytes", ErrSizeMismatch, frame.SizeBytes, d.size)
+	}
+
+	if frame.Checksum != "" && !strings.EqualFold(frame.Checksum, d.checksum) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, frame.Checksum, d.checksum)
+	}
+
+	return nil
+}
+
+// metadata returns the object metadata of the converted blob.
+// The empty digests are omitted.
+func metadata(frame digest, blob digest) map[string]string {
+	m := make(map[string]string)
+	if frame.checksum != "" {
+		m[MetadataFrameChecksum] = frame.checksum
+		m[MetadataFrameSize] = strconv.FormatInt(frame.size, 10)
+	}
+	if blob.checksum != "" {
+		m[MetadataChecksum] = blob.checksum
+		m[MetadataSize] = strconv.FormatInt(blob.size, 10)
+	}
+	return m
+}
+
+// storedFrameSize returns the size of the data frame from the metadata
+// of the converted blob, or the declared size, if the metadata has no size.
+func storedFrameSize(frame *api.InputFrame, metadata map[string]string) int64 {
+	size, err := strconv.ParseInt(metadata[MetadataFrameSize], 10, 64)
+	if err != nil {
+		return frame.SizeBytes
+	}
+	return size
+}
+
+// digester computes the digest of the streamed content.
+type digester struct {
+	h    hash.Hash
+	size int64
+}
+
+func newDigester() *digester {
+	return &digester{h: sha256.New()}
+}
+
+func (d *digester) add(p []byte) {
+	d.h.Write(p)
+	d.size += int64(len(p))
+}
+
+func (d *digester) digest() digest {
+	return digest{
+		checksum: hex.EncodeToString(d.h.Sum(nil)),
+		size:     d.size,
+	}
+}
+
+// verifyingReader computes the digest of the data frame stream,
+// and verifies the data frame once the stream is read.
+// The verification failure is returned instead of EOF.
+type verifyingReader struct {
+	r     io.Reader
+	frame *api.InputFrame
+	d     *digester
+	eof   bool
+}
+
+func newVerifyingReader(r io.Reader, frame *api.InputFrame) *verifyingReader {
+	return &verifyingReader{r: r, frame: frame, d: newDigester()}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.d.add(p[:n])
+
+	if err == io.EOF {
+		v.eof = true
+		if verr := v.d.digest().verify(v.frame); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// finish reads the rest of the stream, that has not been consumed
+// by the converter, so the whole data frame is verified.
+func (v *verifyingReader) finish() error {
+	if v.eof {
+		return v.d.digest().verify(v.frame)
+	}
+	_, err := io.Copy(io.Discard, v)
+	return err
+}
+
+// digestingWriter computes the digest of the converted blob stream.
+type digestingWriter struct {
+	w io.Writer
+	d *digester
+}
+
+func (w *digestingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.d.add(p[:n])
+	return n, err
+}
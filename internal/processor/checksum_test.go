@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/pkg/convert"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestProcessorIntegrity(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		st *storage.MemoryStorage,
+		frame *api.InputFrame,
+		newProcessor func(c convert.Converter) *processor,
+	){
+		"stores checksum and size of the blob": testStoresChecksum,
+		"fails on the checksum mismatch":       testFailsOnChecksumMismatch,
+		"fails on the size mismatch":           testFailsOnSizeMismatch,
+		"verifies the streamed frame":          testVerifiesStreamedFrame,
+		"fails on the streamed frame mismatch": testFailsOnStreamedFrameMismatch,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			st := storage.NewMemoryStorage()
+			st.Put("frames", "frame", storage.MemoryObject{Bytes: []byte("frame")})
+
+			frame := &api.InputFrame{
+				FrameId:       "frame",
+				FrameLocation: &api.Location{Bucket: "frames", ObjectName: "frame"},
+			}
+
+			fn(t, st, frame, func(c convert.Converter) *processor {
+				p, err := NewProcessor(
+					ProcessorConfig{DestinationBucket: "blobs"},
+					c,
+					Storages{api.Location_MINIO: st},
+					nullLogger(),
+				)
+				require.NoError(t, err)
+				return p
+			})
+		})
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// upperConverter converts the frame to upper case, without streaming.
+var upperConverter = converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+	return []byte(strings.ToUpper(string(from))), nil
+})
+
+func testStoresChecksum(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter) *processor,
+) {
+	frame.Checksum = strings.ToUpper(sha256Hex("frame"))
+	frame.SizeBytes = 5
+
+	blob, err := newProcessor(upperConverter).Process(context.Background(), frame)
+	require.NoError(t, err)
+
+	require.Equal(t, sha256Hex("FRAME"), blob.Checksum)
+	require.Equal(t, int64(5), blob.SizeBytes)
+
+	object, ok := st.Object("blobs", blob.ConvertedLocation.ObjectName)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{
+		MetadataFrameChecksum: sha256Hex("frame"),
+		MetadataFrameSize:     "5",
+		MetadataChecksum:      sha256Hex("FRAME"),
+		MetadataSize:          "5",
+	}, object.Metadata)
+}
+
+func testFailsOnChecksumMismatch(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter) *processor,
+) {
+	frame.Checksum = sha256Hex("another frame")
+
+	_, err := newProcessor(upperConverter).Process(context.Background(), frame)
+
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+	require.True(t, failure.IsPermanent(err))
+	require.Empty(t, st.Objects("blobs"))
+}
+
+func testFailsOnSizeMismatch(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter) *processor,
+) {
+	frame.SizeBytes = 42
+
+	_, err := newProcessor(upperConverter).Process(context.Background(), frame)
+
+	require.True(t, errors.Is(err, ErrSizeMismatch))
+	require.True(t, failure.IsPermanent(err))
+}
+
+func testVerifiesStreamedFrame(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter) *processor,
+) {
+	frame.Checksum = sha256Hex("frame")
+
+	blob, err := newProcessor(&identityConverter{}).Process(context.Background(), frame)
+	require.NoError(t, err)
+
+	require.Equal(t, sha256Hex("frame"), blob.Checksum)
+	require.Equal(t, int64(5), blob.SizeBytes)
+	require.Equal(t, int64(5), blob.FrameSizeBytes)
+
+	object, ok := st.Object("blobs", blob.ConvertedLocation.ObjectName)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{
+		MetadataFrameChecksum: sha256Hex("frame"),
+		MetadataFrameSize:     "5",
+		MetadataChecksum:      sha256Hex("frame"),
+		MetadataSize:          "5",
+	}, object.Metadata)
+}
+
+func testFailsOnStreamedFrameMismatch(
+	t *testing.T,
+	st *storage.MemoryStorage,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter) *processor,
+) {
+	frame.Checksum = sha256Hex("another frame")
+
+	_, err := newProcessor(&identityConverter{}).Process(context.Background(), frame)
+
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+	require.Equal(t, failure.KindCorrupt, failure.KindOf(err))
+	require.Empty(t, st.Objects("blobs"))
+}
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+
+	"github.com/weak-head/data-pipe/pkg/convert"
+)
+
+const (
+	// sniffLen is the number of bytes that are used to detect the content type.
+	sniffLen = 512
+)
+
+// resolveContent returns the content with the type, that is either declared, or detected
+// from the head of the converted blob, or the generic binary content type.
+func resolveContent(c convert.Content, sniff bool, head []byte) convert.Content {
+	if c.Type != "" {
+		return c
+	}
+
+	c.Type = contentTypeBLOB
+	if sniff && c.Encoding == "" && len(head) > 0 {
+		c.Type = http.DetectContentType(head)
+	}
+	return c
+}
+
+// peekHead returns the head of the stream that is used to detect the content type,
+// and the reader of the whole stream. The read failure is returned by the reader.
+func peekHead(r io.Reader) ([]byte, io.Reader) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	return head, br
+}
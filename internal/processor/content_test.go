@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/pkg/convert"
+)
+
+func TestContentType(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame){
+		"uses binary content type by default":  testUsesBinaryContentType,
+		"detects the converted blob type":      testDetectsContentType,
+		"detects the streamed blob type":       testDetectsStreamedContentType,
+		"uses the declared content encoding":   testUsesDeclaredEncoding,
+		"resolves the content of the chain":    testResolvesChainContent,
+		"keeps the content of converted blobs": testKeepsContentOfConvertedBlob,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			st := storage.NewMemoryStorage()
+			st.Put("frames", "frame", storage.MemoryObject{Bytes: []byte(`{"frame": 1}`)})
+
+			fn(t, st, newFrame(sha256Hex(`{"frame": 1}`)))
+		})
+	}
+}
+
+// bytesConverter is the identity converter without streaming.
+var bytesConverter = converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+	return from, nil
+})
+
+func testUsesBinaryContentType(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame) {
+	blob, object := processStored(t, newTestProcessor(t, st, withConverter(bytesConverter)), st, frame)
+
+	require.Equal(t, "application/octet-stream", blob.ContentType)
+	require.Equal(t, "", blob.ContentEncoding)
+	require.Equal(t, "application/octet-stream", object.ContentType)
+}
+
+func testDetectsContentType(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame) {
+	p := newTestProcessor(t, st, withConfig(ProcessorConfig{DetectContentType: true}), withConverter(bytesConverter))
+
+	blob, object := processStored(t, p, st, frame)
+
+	require.Equal(t, "text/plain; charset=utf-8", blob.ContentType)
+	require.Equal(t, "text/plain; charset=utf-8", object.ContentType)
+}
+
+func testDetectsStreamedContentType(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame) {
+	p := newTestProcessor(t, st, withConfig(ProcessorConfig{DetectContentType: true}))
+
+	blob, object := processStored(t, p, st, frame)
+
+	require.Equal(t, "text/plain; charset=utf-8", blob.ContentType)
+	require.Equal(t, "text/plain; charset=utf-8", object.ContentType)
+	require.Equal(t, []byte(`{"frame": 1}`), object.Bytes)
+}
+
+func testUsesDeclaredEncoding(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame) {
+	converter, err := NewConverter(ConverterConfig{
+		Kind:    ConverterGzip,
+		Options: convert.Options{"contentType": "application/json"},
+	}, nullLogger())
+	require.NoError(t, err)
+
+	p := newTestProcessor(t, st, withConfig(ProcessorConfig{DetectContentType: true}), withConverter(converter))
+
+	blob, object := processStored(t, p, st, frame)
+
+	require.Equal(t, "application/json", blob.ContentType)
+	require.Equal(t, "gzip", blob.ContentEncoding)
+	require.Equal(t, "application/json", object.ContentType)
+	require.Equal(t, "gzip", object.ContentEncoding)
+}
+
+func testResolvesChainContent(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame) {
+	json := converterFunc(func(ctx context.Context, from []byte) ([]byte, error) { return from, nil })
+	gzip := &gzipConverter{level: -1}
+
+	for _, tc := range []struct {
+		steps   []ChainStep
+		content convert.Content
+	}{
+		{
+			steps:   []ChainStep{{Kind: "gzip", Converter: gzip}},
+			content: convert.Content{Encoding: "gzip"},
+		},
+		{
+			steps:   []ChainStep{{Kind: "json", Converter: &contentConverter{json, convert.Content{Type: "application/json"}}}, {Kind: "gzip", Converter: gzip}},
+			content: convert.Content{Type: "application/json", Encoding: "gzip"},
+		},
+		{
+			steps:   []ChainStep{{Kind: "gzip", Converter: gzip}, {Kind: "gunzip", Converter: &gunzipConverter{}}},
+			content: convert.Content{},
+		},
+	} {
+		chain, err := NewChainConverter(tc.steps, nullLogger())
+		require.NoError(t, err)
+		require.Equal(t, tc.content, chain.Content())
+	}
+}
+
+func testKeepsContentOfConvertedBlob(t *testing.T, st *storage.MemoryStorage, frame *api.InputFrame) {
+	converter := &contentConverter{bytesConverter, convert.Content{Type: "application/json"}}
+	p := newTestProcessor(t, st, withConfig(ProcessorConfig{Idempotent: true}), withConverter(converter))
+
+	first, _ := processStored(t, p, st, frame)
+	second, _ := processStored(t, p, st, frame)
+
+	require.Equal(t, "application/json", second.ContentType)
+	require.Equal(t, first, second)
+}
+
+// contentConverter declares the content of the converter.
+type contentConverter struct {
+	convert.Converter
+
+	content convert.Content
+}
+
+func (c *contentConverter) Content() convert.Content {
+	return c.content
+}
@@ -1,13 +1,73 @@
 package processor
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 
-type converter struct {}
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/pkg/convert"
+)
 
-func NewConverter() (*converter, error) {
-	return &converter{}, nil
+const (
+	// ConverterIdentity is the kind of the converter
+	// that returns the data frame as is.
+	ConverterIdentity = "identity"
+)
+
+var (
+	// ErrUnknownConverter happens when no converter
+	// is registered with the configured kind.
+	ErrUnknownConverter = errors.New("unknown converter kind")
+)
+
+// NewConverter creates a new converter of the configured kind,
+// that is registered with convert.Register, or the chain of such converters.
+// It returns an error if the converter is not registered,
+// or if the converter creation has failed.
+func NewConverter(config ConverterConfig, log logger.Log) (convert.Converter, error) {
+	var (
+		converter convert.Converter
+		err       error
+	)
+	if config.Kind == ConverterChain {
+		// The chain is not registered, as its steps
+		// are created with the service log.
+		converter, err = newChainConverterFromOptions(config.Options, log)
+	} else {
+		factory, ok := convert.Lookup(config.Kind)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownConverter, config.Kind)
+		}
+		converter, err = factory(config.Options, log)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("converter %q: %w", config.Kind, err)
+	}
+	return converter, nil
 }
 
-func (c *converter) Convert(ctx context.Context, from []byte) (to []byte, err error) {
+func init() {
+	convert.Register(ConverterIdentity, newIdentityConverter)
+}
+
+// identityConverter returns the data frame as is.
+type identityConverter struct{}
+
+func newIdentityConverter(options convert.Options, log convert.Logger) (convert.Converter, error) {
+	var config struct{}
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+	return &identityConverter{}, nil
+}
+
+func (c *identityConverter) Convert(ctx context.Context, from []byte) (to []byte, err error) {
 	return from, nil
 }
+
+func (c *identityConverter) ConvertStream(ctx context.Context, from io.Reader, to io.Writer) error {
+	_, err := io.Copy(to, from)
+	return err
+}
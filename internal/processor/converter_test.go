@@ -1 +1,150 @@
 package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/validation"
+	"github.com/weak-head/data-pipe/pkg/convert"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestConverterRegistry(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"creates registered converter":       testCreatesRegisteredConverter,
+		"fails on unknown converter":         testFailsOnUnknownConverter,
+		"fails on unknown converter options": testFailsOnUnknownOptions,
+		"creates third-party converter":      testCreatesThirdPartyConverter,
+		"validates converter kind":           testValidatesConverterKind,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func testCreatesRegisteredConverter(t *testing.T) {
+	c, err := NewConverter(ConverterConfig{Kind: ConverterIdentity}, nullLogger())
+	require.NoError(t, err)
+
+	to, err := c.Convert(context.Background(), []byte("frame"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("frame"), to)
+}
+
+func testFailsOnUnknownConverter(t *testing.T) {
+	_, err := NewConverter(ConverterConfig{Kind: "unknown"}, nullLogger())
+	require.True(t, errors.Is(err, ErrUnknownConverter))
+}
+
+func testFailsOnUnknownOptions(t *testing.T) {
+	_, err := NewConverter(ConverterConfig{
+		Kind:    ConverterIdentity,
+		Options: convert.Options{"level": 1},
+	}, nullLogger())
+	require.Error(t, err)
+}
+
+func testCreatesThirdPartyConverter(t *testing.T) {
+	convert.Register("test-third-party", func(options convert.Options, log convert.Logger) (convert.Converter, error) {
+		return converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+			return []byte("converted"), nil
+		}), nil
+	})
+	require.Contains(t, convert.Kinds(), "test-third-party")
+
+	c, err := NewConverter(ConverterConfig{Kind: "test-third-party"}, nullLogger())
+	require.NoError(t, err)
+
+	to, err := c.Convert(context.Background(), []byte("frame"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("converted"), to)
+}
+
+func testValidatesConverterKind(t *testing.T) {
+	require.NoError(t, ConverterConfig{Kind: ConverterGzip}.Validate())
+
+	var errs validation.Errors
+	require.True(t, errors.As(ConverterConfig{Kind: "unknown"}.Validate(), &errs))
+	require.Equal(t, "kind", errs[0].Path)
+	require.True(t, errors.Is(errs[0].Err, ErrUnknownConverter))
+}
+
+func nullLogger() logger.Log {
+	log, _ := logger.NewNullLogger()
+	return log
+}
+
+// converterFunc is an adapter to use a function as a converter.
+type converterFunc func(ctx context.Context, from []byte) ([]byte, error)
+
+func (f converterFunc) Convert(ctx context.Context, from []byte) ([]byte, error) {
+	return f(ctx, from)
+}
+
+func TestGzipConverter(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"compresses data frame":        testGzipCompresses,
+		"fails on invalid compression": testGzipFailsOnInvalidLevel,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func testGzipCompresses(t *testing.T) {
+	c, err := NewConverter(ConverterConfig{
+		Kind:    ConverterGzip,
+		Options: convert.Options{"level": 9},
+	}, nullLogger())
+	require.NoError(t, err)
+
+	frame := bytes.Repeat([]byte("frame"), 100)
+	to, err := c.Convert(context.Background(), frame)
+	require.NoError(t, err)
+	require.Less(t, len(to), len(frame))
+
+	r, err := gzip.NewReader(bytes.NewReader(to))
+	require.NoError(t, err)
+	from, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, frame, from)
+}
+
+func testGzipFailsOnInvalidLevel(t *testing.T) {
+	_, err := NewConverter(ConverterConfig{
+		Kind:    ConverterGzip,
+		Options: convert.Options{"level": 42},
+	}, nullLogger())
+	require.Error(t, err)
+}
+
+func testGzipStreams(t *testing.T) {
+	frame := bytes.Repeat([]byte("frame"), 100)
+
+	var compressed, decompressed bytes.Buffer
+	require.NoError(t, (&gzipConverter{level: gzip.BestSpeed}).ConvertStream(
+		context.Background(), bytes.NewReader(frame), &compressed))
+	require.NoError(t, (&gunzipConverter{}).ConvertStream(
+		context.Background(), &compressed, &decompressed))
+
+	require.Equal(t, frame, decompressed.Bytes())
+}
+
+func testGunzipStreamFailsOnCorruptedFrame(t *testing.T) {
+	err := (&gunzipConverter{}).ConvertStream(
+		context.Background(), bytes.NewReader([]byte("frame")), io.Discard)
+	require.Equal(t, failure.KindCorrupt, failure.KindOf(err))
+}
+
+func testGunzipStreamKeepsReadFailures(t *testing.T) {
+	readErr := failure.Transient(errors.New("connection reset"))
+	err := (&gunzipConverter{}).ConvertStream(
+		context.Background(), iotest.ErrReader(readErr), io.Discard)
+	require.Equal(t, readErr, err)
+}
@@ -0,0 +1,178 @@
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+
+	"github.com/weak-head/data-pipe/pkg/convert"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+const (
+	// ConverterGzip is the kind of the converter
+	// that compresses the data frame with gzip.
+	ConverterGzip = "gzip"
+
+	// ConverterGunzip is the kind of the converter
+	// that decompresses the gzip compressed data frame.
+	ConverterGunzip = "gunzip"
+
+	// encodingGzip is the content encoding of the gzip compressed blob.
+	encodingGzip = "gzip"
+)
+
+// GzipConfig is the configuration section of the gzip converter.
+type GzipConfig struct {
+	// Compression level, from 1 (best speed) to 9 (best compression).
+	// The default compression level is used if not set.
+	Level int
+
+	// ContentType of the data frame, that is compressed.
+	// The generic binary content type is used if not set.
+	ContentType string
+}
+
+func init() {
+	convert.Register(ConverterGzip, newGzipConverter)
+	convert.Register(ConverterGunzip, newGunzipConverter)
+}
+
+// gzipConverter compresses the data frame with gzip.
+type gzipConverter struct {
+	level       int
+	contentType string
+}
+
+func newGzipConverter(options convert.Options, log convert.Logger) (convert.Converter, error) {
+	config := GzipConfig{Level: gzip.DefaultCompression}
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	// Fail fast on the invalid compression level.
+	if _, err := gzip.NewWriterLevel(nil, config.Level); err != nil {
+		return nil, err
+	}
+
+	return &gzipConverter{level: config.Level, contentType: config.ContentType}, nil
+}
+
+// Content returns the gzip encoding of the configured content type.
+func (c *gzipConverter) Content() convert.Content {
+	return convert.Content{Type: c.contentType, Encoding: encodingGzip}
+}
+
+func (c *gzipConverter) Convert(ctx context.Context, from []byte) (to []byte, err error) {
+	var buf bytes.Buffer
+
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(from); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c *gzipConverter) ConvertStream(ctx context.Context, from io.Reader, to io.Writer) error {
+	w, err := gzip.NewWriterLevel(to, c.level)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, from); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// gunzipConverter decompresses the gzip compressed data frame.
+type gunzipConverter struct{}
+
+func newGunzipConverter(options convert.Options, log convert.Logger) (convert.Converter, error) {
+	var config struct{}
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+	return &gunzipConverter{}, nil
+}
+
+// Convert decompresses the data frame.
+// The data frame that is not a valid gzip stream is corrupted.
+func (c *gunzipConverter) Convert(ctx context.Context, from []byte) (to []byte, err error) {
+	r, err := gzip.NewReader(bytes.NewReader(from))
+	if err != nil {
+		return nil, failure.Corrupt(err)
+	}
+
+	to, err = io.ReadAll(r)
+	if err != nil {
+		return nil, failure.Corrupt(err)
+	}
+
+	return to, nil
+}
+
+// ConvertStream decompresses the data frame stream.
+// The data frame that is not a valid gzip stream is corrupted,
+// the failures to read or write the stream are returned as is.
+func (c *gunzipConverter) ConvertStream(ctx context.Context, from io.Reader, to io.Writer) error {
+	src := &failedReader{r: from}
+
+	r, err := gzip.NewReader(src)
+	if err != nil {
+		return classifyGunzip(src, err)
+	}
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := to.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return classifyGunzip(src, err)
+		}
+	}
+}
+
+// failedReader records the failure of the underlying reader.
+type failedReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failedReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err != nil && err != io.EOF {
+		f.err = err
+	}
+	return n, err
+}
+
+// classifyGunzip classifies the decompression failure.
+// The failure is caused by the corrupted data frame,
+// unless the data frame stream could not be read.
+func classifyGunzip(src *failedReader, err error) error {
+	if src.err != nil {
+		return src.err
+	}
+	return failure.Corrupt(err)
+}
@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/internal/validation"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+const (
+	// defaultObjectName is the template of the converted blob object name,
+	// that is used if the template is not configured.
+	defaultObjectName = "converted_{{.FrameID}}.blob"
+)
+
+var (
+	// ErrInvalidObjectName happens when the object name template is not valid.
+	ErrInvalidObjectName = errors.New("invalid object name template")
+
+	// ErrEmptyObjectName happens when the object name template
+	// results in the empty object name of the data frame.
+	ErrEmptyObjectName = failure.Corrupt(errors.New("empty converted blob object name"))
+
+	// ErrChecksumNotRequired happens when the object name template
+	// uses the checksum of the data frame, that is not required.
+	ErrChecksumNotRequired = errors.New("object name uses the checksum, that is not required")
+)
+
+// DestinationRule selects the destination bucket of the data frames,
+// that match the source bucket and object name patterns.
+// The patterns have the path.Match syntax, and the empty pattern matches any value.
+type DestinationRule struct {
+	// SourceBucket is the pattern of the data frame bucket.
+	SourceBucket string
+
+	// SourceObject is the pattern of the data frame object name.
+	SourceObject string
+
+	// Bucket for the converted blobs of the matched data frames.
+	Bucket string
+}
+
+// Validate returns the aggregated validation failures of the destination rule.
+func (r DestinationRule) Validate() error {
+	var errs validation.Errors
+	errs.Add("bucket", validation.Required(r.Bucket))
+	if _, err := path.Match(r.SourceBucket, ""); err != nil {
+		errs.Add("sourcebucket", err)
+	}
+	if _, err := path.Match(r.SourceObject, ""); err != nil {
+		errs.Add("sourceobject", err)
+	}
+	return errs.Err()
+}
+
+// matches reports whether the data frame location matches the rule.
+// The patterns are validated before the rule is used.
+func (r DestinationRule) matches(location *api.Location) bool {
+	return matchPattern(r.SourceBucket, location.Bucket) &&
+		matchPattern(r.SourceObject, location.ObjectName)
+}
+
+func matchPattern(pattern string, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// ObjectNameData is the data of the object name template.
+type ObjectNameData struct {
+	FrameID string
+
+	// SourceBucket and SourceObject are the location of the data frame.
+	SourceBucket string
+	SourceObject string
+
+	// Time of the data frame, in UTC, and its date partitions,
+	// e.g. "2021", "06", "01" and "13". The time of the data frame is
+	// the same for the redelivered data frame, and the processing time
+	// is used only if the data frame has no time.
+	Time  time.Time
+	Year  string
+	Month string
+	Day   string
+	Hour  string
+
+	// Converter is the kind of the data frame converter.
+	Converter string
+
+	// Checksum is the lowercase checksum of the data frame.
+	// The template could use the checksum only if the checksum is required
+	// (see ProcessorConfig.RequireChecksum).
+	Checksum string
+}
+
+// parseObjectName parses the object name template.
+// The default template is used if the template is empty.
+func parseObjectName(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultObjectName
+	}
+
+	tmpl, err := template.New("objectname").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidObjectName, err)
+	}
+	return tmpl, nil
+}
+
+// usesChecksum reports whether the object name depends on the checksum
+// of the data frame, i.e. the name of the data frame without the checksum
+// differs from the name of the data frame with the checksum.
+func usesChecksum(tmpl *template.Template) bool {
+	var without, with strings.Builder
+	if err := tmpl.Execute(&without, ObjectNameData{}); err != nil {
+		return false
+	}
+	if err := tmpl.Execute(&with, ObjectNameData{Checksum: "0"}); err != nil {
+		return false
+	}
+	return without.String() != with.String()
+}
+
+// frameTime returns the time of the data frame in UTC,
+// or the processing time if the data frame has no time.
+func (p *processor) frameTime(frame *api.InputFrame) time.Time {
+	if frame.TimestampMs != 0 {
+		return time.UnixMilli(frame.TimestampMs).UTC()
+	}
+	return p.now().UTC()
+}
+
+// blobLocation returns the location of the blob,
+// that is converted from the data frame.
+func (p *processor) blobLocation(frame *api.InputFrame) (*api.Location, error) {
+	now := p.frameTime(frame)
+	data := ObjectNameData{
+		FrameID:      frame.FrameId,
+		SourceBucket: frame.FrameLocation.Bucket,
+		SourceObject: frame.FrameLocation.ObjectName,
+		Time:         now,
+		Year:         now.Format("2006"),
+		Month:        now.Format("01"),
+		Day:          now.Format("02"),
+		Hour:         now.Format("15"),
+		Converter:    p.config.ConverterKind,
+		Checksum:     strings.ToLower(frame.Checksum),
+	}
+
+	var objectName strings.Builder
+	if err := p.objectName.Execute(&objectName, data); err != nil {
+		return nil, failure.Corrupt(err)
+	}
+
+	if strings.TrimSpace(objectName.String()) == "" {
+		return nil, ErrEmptyObjectName
+	}
+
+	return &api.Location{
+		Kind:       p.destinationKind,
+		Bucket:     p.destinationBucket(frame.FrameLocation),
+		ObjectName: objectName.String(),
+	}, nil
+}
+
+// destinationBucket returns the bucket of the first matching destination rule,
+// or the default destination bucket if none of the rules match.
+func (p *processor) destinationBucket(location *api.Location) string {
+	for _, rule := range p.config.DestinationRules {
+		if rule.matches(location) {
+			return rule.Bucket
+		}
+	}
+	return p.config.DestinationBucket
+}
@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/internal/validation"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestBlobNaming(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, st *storage.MemoryStorage){
+		"uses the default object name":         testUsesDefaultObjectName,
+		"names the blob with the template":     testNamesBlobWithTemplate,
+		"names the blob by the frame time":     testNamesBlobByFrameTime,
+		"rejects the frame without checksum":   testRejectsFrameWithoutChecksum,
+		"selects the bucket of the first rule": testSelectsBucketByRules,
+		"fails on the empty object name":       testFailsOnEmptyObjectName,
+		"validates the naming configuration":   testValidatesNaming,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			st := storage.NewMemoryStorage()
+			st.Put("frames", "2021/frame.bin", storage.MemoryObject{Bytes: []byte("frame")})
+			st.Put("camera-1", "frame.bin", storage.MemoryObject{Bytes: []byte("frame")})
+
+			fn(t, st)
+		})
+	}
+}
+
+func testUsesDefaultObjectName(t *testing.T, st *storage.MemoryStorage) {
+	blob, err := newTestProcessor(t, st).Process(context.Background(), newFrameAt("frames", "2021/frame.bin", ""))
+	require.NoError(t, err)
+
+	require.Equal(t, "blobs", blob.ConvertedLocation.Bucket)
+	require.Equal(t, "converted_frame.blob", blob.ConvertedLocation.ObjectName)
+}
+
+func testNamesBlobWithTemplate(t *testing.T, st *storage.MemoryStorage) {
+	p := newTestProcessor(t, st,
+		withConfig(ProcessorConfig{
+			ObjectName:      "{{.SourceBucket}}/{{.Year}}/{{.Month}}/{{.Day}}/{{.Hour}}/{{.SourceObject}}.{{.Converter}}.{{.Checksum}}",
+			RequireChecksum: true,
+			ConverterKind:   ConverterIdentity,
+		}),
+		withNow(time.Date(2021, time.June, 1, 13, 30, 0, 0, time.FixedZone("UTC+3", 3*60*60))),
+	)
+
+	blob, err := p.Process(context.Background(), newFrameAt("frames", "2021/frame.bin", strings.ToUpper(sha256Hex("frame"))))
+	require.NoError(t, err)
+
+	require.Equal(t, "frames/2021/06/01/10/2021/frame.bin.identity."+sha256Hex("frame"), blob.ConvertedLocation.ObjectName)
+	require.Equal(t, []string{blob.ConvertedLocation.ObjectName}, st.Objects("blobs"))
+}
+
+func testNamesBlobByFrameTime(t *testing.T, st *storage.MemoryStorage) {
+	p := newTestProcessor(t, st,
+		withConfig(ProcessorConfig{ObjectName: "{{.Year}}/{{.Month}}/{{.Day}}/{{.Hour}}/{{.FrameID}}"}),
+		withNow(time.Date(2021, time.June, 1, 13, 30, 0, 0, time.UTC)),
+	)
+	frame := newFrameAt("frames", "2021/frame.bin", "")
+	frame.TimestampMs = time.Date(2020, time.December, 31, 23, 59, 0, 0, time.UTC).UnixMilli()
+
+	blob, err := p.Process(context.Background(), frame)
+	require.NoError(t, err)
+	require.Equal(t, "2020/12/31/23/frame", blob.ConvertedLocation.ObjectName)
+
+	// The redelivered data frame is processed later, but has the same name.
+	p.now = func() time.Time { return time.Date(2021, time.June, 2, 0, 0, 0, 0, time.UTC) }
+	blob, err = p.Process(context.Background(), frame)
+	require.NoError(t, err)
+	require.Equal(t, "2020/12/31/23/frame", blob.ConvertedLocation.ObjectName)
+}
+
+func testRejectsFrameWithoutChecksum(t *testing.T, st *storage.MemoryStorage) {
+	p := newTestProcessor(t, st, withConfig(ProcessorConfig{ObjectName: "{{.Checksum}}", RequireChecksum: true}))
+
+	_, err := p.Process(context.Background(), newFrameAt("frames", "2021/frame.bin", ""))
+
+	require.True(t, errors.Is(err, ErrNoChecksum))
+	require.True(t, failure.IsPermanent(err))
+	require.Empty(t, st.Objects("blobs"))
+}
+
+func testSelectsBucketByRules(t *testing.T, st *storage.MemoryStorage) {
+	p := newTestProcessor(t, st, withConfig(ProcessorConfig{
+		DestinationRules: []DestinationRule{
+			{SourceBucket: "camera-*", SourceObject: "*.bin", Bucket: "camera-blobs"},
+			{SourceBucket: "camera-*", Bucket: "camera-other"},
+		},
+	}))
+
+	blob, err := p.Process(context.Background(), newFrameAt("camera-1", "frame.bin", ""))
+	require.NoError(t, err)
+	require.Equal(t, "camera-blobs", blob.ConvertedLocation.Bucket)
+
+	blob, err = p.Process(context.Background(), newFrameAt("frames", "2021/frame.bin", ""))
+	require.NoError(t, err)
+	require.Equal(t, "blobs", blob.ConvertedLocation.Bucket)
+}
+
+func testFailsOnEmptyObjectName(t *testing.T, st *storage.MemoryStorage) {
+	p := newTestProcessor(t, st, withConfig(ProcessorConfig{ObjectName: "{{if false}}blob{{end}}"}))
+
+	_, err := p.Process(context.Background(), newFrameAt("frames", "2021/frame.bin", ""))
+
+	require.True(t, errors.Is(err, ErrEmptyObjectName))
+	require.True(t, failure.IsPermanent(err))
+}
+
+func testValidatesNaming(t *testing.T, st *storage.MemoryStorage) {
+	config := ProcessorConfig{
+		DestinationBucket: "blobs",
+		ObjectName:        "{{.Unknown}}",
+		DestinationRules: []DestinationRule{
+			{SourceBucket: "camera-*", Bucket: "camera-blobs"},
+			{SourceObject: "[", Bucket: ""},
+		},
+	}
+
+	var errs validation.Errors
+	require.True(t, errors.As(config.Validate(), &errs))
+
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	require.Equal(t, []string{
+		"objectname",
+		"destinationrules.1.bucket",
+		"destinationrules.1.sourceobject",
+	}, paths)
+	require.True(t, errors.Is(errs[0], ErrInvalidObjectName))
+
+	// The checksum is optional, so the object name could not use it.
+	config = ProcessorConfig{DestinationBucket: "blobs", ObjectName: "{{.FrameID}}/{{.Checksum}}"}
+	require.True(t, errors.As(config.Validate(), &errs))
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], ErrChecksumNotRequired))
+
+	config.RequireChecksum = true
+	require.NoError(t, config.Validate())
+}
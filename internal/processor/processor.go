@@ -4,10 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	api "github.com/weak-head/data-pipe/api/v1"
 	"github.com/weak-head/data-pipe/internal/logger"
 	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/internal/tracing"
+	"github.com/weak-head/data-pipe/internal/validation"
+	"github.com/weak-head/data-pipe/pkg/convert"
+	"github.com/weak-head/data-pipe/pkg/failure"
 )
 
 var (
@@ -16,20 +28,67 @@ var (
 
 	// ErrNoStorageProvided happens when storage is not provided.
 	ErrNoStorageProvided = errors.New("no storage provided")
+
+	// ErrNoFrameLocation happens when the data frame has no location.
+	ErrNoFrameLocation = failure.Corrupt(errors.New("no data frame location"))
+
+	// errStoreAborted is returned to the stream converter,
+	// if the converted blob stream is no longer consumed by the storage.
+	errStoreAborted = errors.New("converted blob stream is no longer stored")
 )
 
 const (
 	contentTypeBLOB = "application/octet-stream"
+
+	// instrumentationName is the name of the processor tracer.
+	instrumentationName = "github.com/weak-head/data-pipe/internal/processor"
 )
 
 // ConverterConfig
 type ConverterConfig struct {
+	// Kind of the registered converter (see convert.Register).
 	Kind string
+
+	// Options is the configuration section of the converter.
+	Options convert.Options
 }
 
 // ProcessorConfig
 type ProcessorConfig struct {
 	DestinationBucket string
+
+	// Kind of the storage that stores the converted blobs,
+	// e.g. "minio" or "filesystem". The minio storage is used if not set.
+	DestinationKind string
+
+	// Idempotent enables the lookup of the already converted blob,
+	// so the redelivered data frames are not converted again.
+	Idempotent bool
+
+	// RequireChecksum rejects the data frames that have no checksum,
+	// so the object name template could use the checksum of the data frame.
+	RequireChecksum bool
+
+	// DetectContentType enables the detection of the converted blob content type,
+	// if the content type is not declared by the converter (see convert.ContentConverter).
+	DetectContentType bool
+
+	// MetadataAttributes are the names of the data frame attributes,
+	// that are stored as the converted blob metadata.
+	MetadataAttributes []string
+
+	// ObjectName is the text/template of the converted blob object name,
+	// that is executed with ObjectNameData, e.g. "{{.Year}}/{{.Month}}/{{.FrameID}}.blob".
+	// The "converted_{{.FrameID}}.blob" template is used if not set.
+	ObjectName string
+
+	// DestinationRules select the destination bucket by the data frame location.
+	// The first matching rule wins, and DestinationBucket is used if none match.
+	DestinationRules []DestinationRule
+
+	// ConverterKind is the kind of the data frame converter,
+	// that is set from the converter configuration.
+	ConverterKind string `mapstructure:"-"`
 }
 
 // Config
@@ -37,87 +96,323 @@ type Config struct {
 	Processor ProcessorConfig
 	Converter ConverterConfig
 	Storage   storage.StorageConfig
+
+	// Filesystem is the local storage of the FILESYSTEM locations.
+	// The filesystem storage is disabled if the root directory is not configured.
+	Filesystem storage.FilesystemConfig
 }
 
-// Converter is the interface that wraps the basic Convert method.
-//
-// Convert process the data frame and returns the converted blob.
-// Convert must return a non-nil error if convertion of the data frame has failed.
-type Converter interface {
-	Convert(ctx context.Context, from []byte) (to []byte, err error)
+// Validate returns the aggregated validation failures of the processor configuration.
+func (c ProcessorConfig) Validate() error {
+	var errs validation.Errors
+	errs.Add("destinationbucket", validation.Required(c.DestinationBucket))
+	if _, err := c.destinationKind(); err != nil {
+		errs.Add("destinationkind", ErrUnknownLocationKind)
+	}
+	if tmpl, err := parseObjectName(c.ObjectName); err != nil {
+		errs.Add("objectname", err)
+	} else if err := tmpl.Execute(io.Discard, ObjectNameData{}); err != nil {
+		errs.Add("objectname", fmt.Errorf("%w: %s", ErrInvalidObjectName, err))
+	} else if !c.RequireChecksum && usesChecksum(tmpl) {
+		errs.Add("objectname", ErrChecksumNotRequired)
+	}
+	errs.Add("metadataattributes", validateAttributes(c.MetadataAttributes))
+	for i, rule := range c.DestinationRules {
+		errs.Merge(fmt.Sprintf("destinationrules.%d", i), rule.Validate())
+	}
+	return errs.Err()
 }
 
-// Storage
+// destinationKind returns the kind of the destination storage.
+func (c ProcessorConfig) destinationKind() (api.Location_Kind, error) {
+	if c.DestinationKind == "" {
+		return api.Location_MINIO, nil
+	}
+	return ParseLocationKind(c.DestinationKind)
+}
+
+// Validate returns the aggregated validation failures of the converter configuration.
+func (c ConverterConfig) Validate() error {
+	var errs validation.Errors
+	if _, ok := convert.Lookup(c.Kind); !ok {
+		errs.Add("kind", ErrUnknownConverter)
+	}
+	return errs.Err()
+}
+
+// Validate returns the aggregated validation failures
+// of the processor, converter and storage configuration.
+func (c Config) Validate() error {
+	var errs validation.Errors
+	errs.Merge("processor", c.Processor.Validate())
+	errs.Merge("converter", c.Converter.Validate())
+	errs.Merge("storage", c.Storage.Validate())
+	if kind, _ := c.Processor.destinationKind(); c.Filesystem.Root != "" || kind == api.Location_FILESYSTEM {
+		errs.Merge("filesystem", c.Filesystem.Validate())
+	}
+	return errs.Err()
+}
+
+// Storage is the interface of the object storage.
+//
+// The storage failures should be classified (see failure.Kind),
+// so the pipeline could decide whether to retry the operation.
 type Storage interface {
-	Store(ctx context.Context, bucket string, objectName string, objectBytes []byte, contentType string) error
+	Store(ctx context.Context, bucket string, objectName string, objectBytes []byte, info storage.ObjectInfo) error
 	Retrieve(ctx context.Context, bucket string, objectName string) ([]byte, error)
 }
 
+// StreamStorage is the interface of the object storage,
+// that retrieves and stores the objects as streams.
+//
+// StoreStream reads the object until EOF, and must not store the object
+// if the reader fails. The reader returned by RetrieveStream
+// should return the classified failures, and should be closed by the caller.
+type StreamStorage interface {
+	StoreStream(ctx context.Context, bucket string, objectName string, r io.Reader, info storage.ObjectInfo) error
+	RetrieveStream(ctx context.Context, bucket string, objectName string) (io.ReadCloser, error)
+}
+
+// StatStorage is the interface that wraps the Stat method.
+//
+// Stat returns the description of the stored object,
+// and the NotFound failure if the object doesn't exist (see failure.NotFound).
+// The destination storage that implements StatStorage is used in the idempotent mode.
+type StatStorage interface {
+	Stat(ctx context.Context, bucket string, objectName string) (storage.ObjectStat, error)
+}
+
+// InfoStorage is the interface that wraps the UpdateInfo method.
+//
+// UpdateInfo replaces the content type, encoding and metadata of the stored object.
+// The streamed blob is stored before its checksum is known, so the stream destination
+// that implements InfoStorage gets the checksum metadata once the blob is stored.
+// The streamed blobs of other destinations are stored without the checksum metadata,
+// and they are always converted again in the idempotent mode.
+type InfoStorage interface {
+	UpdateInfo(ctx context.Context, bucket string, objectName string, info storage.ObjectInfo) error
+}
+
 // processor is a wrapper over the converter that interacts
-// with the provided storage to retrive data frames and store
+// with the provided storages to retrive data frames and store
 // the converted blob.
 type processor struct {
 	config ProcessorConfig
 
-	converter Converter
-	storage   Storage
+	converter convert.Converter
+	storages  Storages
 
-	log logger.Log
+	// destination is the storage of the converted blobs.
+	destination     Storage
+	destinationKind api.Location_Kind
+
+	// objectName is the template of the converted blob object name,
+	// and now is the clock of the date partitions.
+	objectName *template.Template
+	now        func() time.Time
+
+	tracer trace.Tracer
+	log    logger.Log
 }
 
 // NewProcessor creates a new data frame processor.
+// The data frames are retrieved from the storage of the frame location kind,
+// and the converted blobs are stored to the storage of the destination kind.
 // It returns an error if the creation failed.
 func NewProcessor(
 	config ProcessorConfig,
-	converter Converter,
-	storage Storage,
+	converter convert.Converter,
+	storages Storages,
 	log logger.Log,
 ) (*processor, error) {
 	if converter == nil {
 		return nil, ErrNoConverterProvided
 	}
 
-	if storage == nil {
+	if len(storages) == 0 {
 		return nil, ErrNoStorageProvided
 	}
 
+	destinationKind, err := config.destinationKind()
+	if err != nil {
+		return nil, err
+	}
+
+	destination, err := storages.Route(destinationKind)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoDestinationStorage, destinationKind)
+	}
+
+	objectName, err := parseObjectName(config.ObjectName)
+	if err != nil {
+		return nil, err
+	}
+
 	return &processor{
-		config:    config,
-		converter: converter,
-		storage:   storage,
-		log:       log.WithField(logger.FieldPackage, "processor"),
+		config:          config,
+		converter:       converter,
+		storages:        storages,
+		destination:     destination,
+		destinationKind: destinationKind,
+		objectName:      objectName,
+		now:             time.Now,
+		tracer:          otel.Tracer(instrumentationName),
+		log:             log.WithField(logger.FieldPackage, "processor"),
 	}, nil
 }
 
 // Process retrieves the data frame from the storage,
 // converts using the given converter and uploads results back the the storage.
 // Process returns an error in case if the data frame convertion has failed.
+// The returned error is classified with the failure kind.
+//
+// The data frame is verified against its checksum and size, if they are set,
+// and the mismatch is a permanent failure. The data frame without the checksum
+// is a permanent failure, if the checksum is required. The checksum and size
+// of the converted blob are stored as the object metadata.
+//
+// The data frame is streamed, if the converter implements convert.StreamConverter
+// and both source and destination storages implement StreamStorage.
+// The streamed blob is stored before its checksum is known, so its checksum
+// metadata is stored once the blob is stored, if the destination implements InfoStorage.
+//
+// In the idempotent mode the data frame with the checksum is not converted,
+// if the destination already has the blob that has been converted
+// from the data frame with the same checksum.
+//
+// The processing and its stages are traced with the spans,
+// that are the children of the span of the given context.
 func (p *processor) Process(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
+	ctx, span := p.tracer.Start(ctx, "processor.Process", trace.WithAttributes(
+		attribute.String("frame.id", frame.FrameId),
+	))
+	blob, err := p.process(ctx, frame)
+	tracing.End(span, err)
+	return blob, err
+}
+
+// process processes the data frame, see Process.
+func (p *processor) process(ctx context.Context, frame *api.InputFrame) (*api.ConvertedBlob, error) {
 	log := p.log.WithFields(logger.Fields{
 		logger.FieldFunction: "processor.Process",
 		"frame":           frame.FrameId,
 	})
 	log.Info("Processing a new data frame.")
 
-	frame_bytes, err := p.storage.Retrieve(ctx, frame.FrameLocation.Bucket, frame.FrameLocation.ObjectName)
+	if frame.FrameLocation == nil {
+		log.Error(ErrNoFrameLocation, "Data frame has no location.")
+		return nil, ErrNoFrameLocation
+	}
+
+	if p.config.RequireChecksum && frame.Checksum == "" {
+		log.Error(ErrNoChecksum, "Data frame has no checksum.")
+		return nil, ErrNoChecksum
+	}
+
+	source, err := p.storages.Route(frame.FrameLocation.Kind)
 	if err != nil {
-		log.Error(err, "Failed to retrive the data frame from the storage.")
+		log.Error(err, "Data frame location is not supported.")
 		return nil, err
 	}
 
-	blob_bytes, err := p.converter.Convert(ctx, frame_bytes)
+	location, err := p.blobLocation(frame)
 	if err != nil {
-		log.Error(err, "Failed to convert data frame.")
+		log.Error(err, "Failed to name the converted blob.")
 		return nil, err
 	}
 
-	objectName := getBlobObjectName(frame)
-	if err := p.storage.Store(ctx, p.config.DestinationBucket, objectName, blob_bytes, contentTypeBLOB); err != nil {
-		log.Error(err, "Failed to store the converted data frame.")
+	blob, converted := p.lookupBlob(ctx, frame, location)
+	if converted {
+		log.Info("Data frame has already been converted.")
+		return p.convertedBlob(frame, location, blob), nil
+	}
+
+	converter, streamConverter := p.converter.(convert.StreamConverter)
+	sourceStream, streamSource := source.(StreamStorage)
+	destinationStream, streamDestination := p.destination.(StreamStorage)
+
+	if streamConverter && streamSource && streamDestination {
+		blob, err = p.processStream(ctx, frame, location, converter, sourceStream, destinationStream)
+	} else {
+		blob, err = p.processBytes(ctx, frame, location, source)
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	log.Info("Data frame has been processed.")
+	return p.convertedBlob(frame, location, blob), nil
+}
+
+// storedBlob describes the stored converted blob.
+type storedBlob struct {
+	digest
+
+	content    convert.Content
+	attributes map[string]string
+
+	// frameSize is the size of the data frame,
+	// that the blob has been converted from.
+	frameSize int64
+}
+
+// lookupBlob returns the description of the stored blob, and reports whether the blob
+// has been converted from the data frame with the same checksum.
+// The data frame without the checksum is always converted.
+// The lookup failures are not fatal, the data frame is converted instead.
+func (p *processor) lookupBlob(ctx context.Context, frame *api.InputFrame, location *api.Location) (storedBlob, bool) {
+	if !p.config.Idempotent || frame.Checksum == "" {
+		return storedBlob{}, false
+	}
+
+	destination, ok := p.destination.(StatStorage)
+	if !ok {
+		return storedBlob{}, false
+	}
+
+	log := p.log.WithFields(logger.Fields{
+		logger.FieldFunction: "processor.lookupBlob",
+		"frame":              frame.FrameId,
+	})
+
+	statCtx, span := p.tracer.Start(ctx, "processor.lookup")
+	stat, err := destination.Stat(statCtx, location.Bucket, location.ObjectName)
+	span.End()
+	if err != nil {
+		if failure.KindOf(err) != failure.KindNotFound {
+			log.Warn("Failed to look up the converted blob: ", err)
+		}
+		return storedBlob{}, false
+	}
+
+	if !strings.EqualFold(stat.Metadata[MetadataFrameChecksum], frame.Checksum) {
+		log.Debug("Converted blob has a different data frame checksum.")
+		return storedBlob{}, false
+	}
+
+	attributes, err := loadAttributes(frame.Attributes, stat.Metadata)
+	if err != nil {
+		log.Warn("Failed to restore the converted blob attributes: ", err)
+		return storedBlob{}, false
+	}
+
+	return storedBlob{
+		digest: digest{
+			checksum: stat.Metadata[MetadataChecksum],
+			size:     stat.SizeBytes,
+		},
+		content: convert.Content{
+			Type:     stat.ContentType,
+			Encoding: stat.ContentEncoding,
+		},
+		attributes: attributes,
+		frameSize:  storedFrameSize(frame, stat.Metadata),
+	}, true
+}
+
+// convertedBlob returns the description of the blob,
+// that has been converted from the data frame.
+func (p *processor) convertedBlob(frame *api.InputFrame, location *api.Location, blob storedBlob) *api.ConvertedBlob {
 	return &api.ConvertedBlob{
 		FrameId: frame.FrameId,
 		FrameLocation: &api.Location{
@@ -125,13 +420,194 @@ func (p *processor) Process(ctx context.Context, frame *api.InputFrame) (*api.Co
 			Bucket:     frame.FrameLocation.Bucket,
 			ObjectName: frame.FrameLocation.ObjectName,
 		},
-		ConvertedLocation: &api.Location{
-			Bucket:     p.config.DestinationBucket,
-			ObjectName: objectName,
-		},
-	}, nil
+		ConvertedLocation: location,
+		Checksum:          blob.checksum,
+		SizeBytes:         blob.size,
+		ContentType:       blob.content.Type,
+		ContentEncoding:   blob.content.Encoding,
+		Attributes:        blob.attributes,
+		FrameSizeBytes:    blob.frameSize,
+	}
+}
+
+// processBytes converts the data frame, that is retrieved as a whole,
+// and stores the converted blob. It returns the description of the converted blob.
+func (p *processor) processBytes(
+	ctx context.Context,
+	frame *api.InputFrame,
+	location *api.Location,
+	source Storage,
+) (storedBlob, error) {
+	log := p.log.WithFields(logger.Fields{
+		logger.FieldFunction: "processor.processBytes",
+		"frame":              frame.FrameId,
+	})
+
+	retrieveCtx, span := p.tracer.Start(ctx, "processor.retrieve")
+	frame_bytes, err := source.Retrieve(retrieveCtx, frame.FrameLocation.Bucket, frame.FrameLocation.ObjectName)
+	tracing.End(span, err)
+	if err != nil {
+		log.Error(err, "Failed to retrive the data frame from the storage.")
+		return storedBlob{}, err
+	}
+
+	frameDigest := digestOf(frame_bytes)
+	if err := frameDigest.verify(frame); err != nil {
+		log.Error(err, "Data frame integrity check has failed.")
+		return storedBlob{}, err
+	}
+
+	convertCtx, attrs := convert.WithAttributes(ctx, frame.Attributes)
+	convertCtx, span = p.tracer.Start(convertCtx, "processor.convert")
+	blob_bytes, err := p.converter.Convert(convertCtx, frame_bytes)
+	tracing.End(span, err)
+	if err != nil {
+		log.Error(err, "Failed to convert data frame.")
+		return storedBlob{}, classifyConvert(err)
+	}
+
+	blob := storedBlob{
+		digest:     digestOf(blob_bytes),
+		content:    resolveContent(convert.ContentOf(p.converter), p.config.DetectContentType, blob_bytes),
+		attributes: attrs.Values(),
+		frameSize:  frameDigest.size,
+	}
+	info := storage.ObjectInfo{
+		ContentType:     blob.content.Type,
+		ContentEncoding: blob.content.Encoding,
+		Metadata:        metadata(frameDigest, blob.digest),
+	}
+	storeAttributes(info.Metadata, p.config.MetadataAttributes, blob.attributes)
+	storeConverterAttributes(info.Metadata, frame.Attributes, blob.attributes)
+
+	storeCtx, span := p.tracer.Start(ctx, "processor.store")
+	err = p.destination.Store(storeCtx, location.Bucket, location.ObjectName, blob_bytes, info)
+	tracing.End(span, err)
+	if err != nil {
+		log.Error(err, "Failed to store the converted data frame.")
+		return storedBlob{}, err
+	}
+
+	return blob, nil
+}
+
+// processStream converts the data frame stream, while the converted blob
+// stream is stored, so neither the data frame nor the converted blob
+// is buffered in memory as a whole. It returns the description of the converted blob.
+// The content type is detected from the head of the converted blob stream,
+// that is buffered before the blob is stored.
+func (p *processor) processStream(
+	ctx context.Context,
+	frame *api.InputFrame,
+	location *api.Location,
+	converter convert.StreamConverter,
+	source StreamStorage,
+	destination StreamStorage,
+) (storedBlob, error) {
+	log := p.log.WithFields(logger.Fields{
+		logger.FieldFunction: "processor.processStream",
+		"frame":              frame.FrameId,
+	})
+
+	retrieveCtx, span := p.tracer.Start(ctx, "processor.retrieve")
+	from, err := source.RetrieveStream(retrieveCtx, frame.FrameLocation.Bucket, frame.FrameLocation.ObjectName)
+	tracing.End(span, err)
+	if err != nil {
+		log.Error(err, "Failed to retrive the data frame stream from the storage.")
+		return storedBlob{}, err
+	}
+	defer from.Close()
+
+	frameReader := newVerifyingReader(from, frame)
+	blobDigester := newDigester()
+
+	convertCtx, attrs := convert.WithAttributes(ctx, frame.Attributes)
+
+	pr, pw := io.Pipe()
+	converted := make(chan error, 1)
+	go func() {
+		convertCtx, span := p.tracer.Start(convertCtx, "processor.convert")
+		err := converter.ConvertStream(convertCtx, frameReader, &digestingWriter{w: pw, d: blobDigester})
+		if err == nil {
+			// The blob is not stored, until the whole data frame is verified.
+			err = frameReader.finish()
+		}
+		tracing.End(span, err)
+		pw.CloseWithError(err)
+		converted <- err
+	}()
+
+	var (
+		blob    io.Reader = pr
+		head    []byte
+		content = convert.ContentOf(p.converter)
+	)
+	if p.config.DetectContentType && content.Type == "" && content.Encoding == "" {
+		head, blob = peekHead(pr)
+	}
+	content = resolveContent(content, p.config.DetectContentType, head)
+
+	// The checksum metadata and the attributes added by the converter are stored
+	// once the blob is stored and its checksum is known,
+	// so the partially described blob is never skipped in the idempotent mode.
+	info := storage.ObjectInfo{
+		ContentType:     content.Type,
+		ContentEncoding: content.Encoding,
+		Metadata:        make(map[string]string),
+	}
+	storeAttributes(info.Metadata, p.config.MetadataAttributes, frame.Attributes)
+
+	storeCtx, span := p.tracer.Start(ctx, "processor.store")
+	storeErr := destination.StoreStream(storeCtx, location.Bucket, location.ObjectName, blob, info)
+	tracing.End(span, storeErr)
+
+	// Unblock the converter, if the storage has failed
+	// before the converted blob stream has been consumed.
+	pr.CloseWithError(errStoreAborted)
+	convertErr := <-converted
+
+	// The failed conversion also fails the storage,
+	// so the conversion failure takes precedence.
+	if convertErr != nil && !errors.Is(convertErr, errStoreAborted) {
+		log.Error(convertErr, "Failed to convert data frame stream.")
+		return storedBlob{}, classifyConvert(convertErr)
+	}
+
+	if storeErr != nil {
+		log.Error(storeErr, "Failed to store the converted data frame stream.")
+		return storedBlob{}, storeErr
+	}
+
+	frameDigest := frameReader.d.digest()
+	stored := storedBlob{
+		digest:     blobDigester.digest(),
+		content:    content,
+		attributes: attrs.Values(),
+		frameSize:  frameDigest.size,
+	}
+
+	if infoStorage, ok := destination.(InfoStorage); ok {
+		info.Metadata = metadata(frameDigest, stored.digest)
+		storeAttributes(info.Metadata, p.config.MetadataAttributes, stored.attributes)
+		storeConverterAttributes(info.Metadata, frame.Attributes, stored.attributes)
+
+		updateCtx, span := p.tracer.Start(ctx, "processor.update")
+		err := infoStorage.UpdateInfo(updateCtx, location.Bucket, location.ObjectName, info)
+		tracing.End(span, err)
+		if err != nil {
+			log.Error(err, "Failed to update the converted data frame stream metadata.")
+			return storedBlob{}, err
+		}
+	}
+
+	return stored, nil
 }
 
-func getBlobObjectName(frame *api.InputFrame) string {
-	return fmt.Sprintf("converted_%s.blob", frame.FrameId)
+// classifyConvert classifies the conversion failure.
+// The failures that are not classified by the converter are rejected.
+func classifyConvert(err error) error {
+	if failure.KindOf(err) == failure.KindUnknown {
+		return failure.Rejected(err)
+	}
+	return err
 }
@@ -1,16 +1,29 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
 	api "github.com/weak-head/data-pipe/api/v1"
 	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/pkg/convert"
+	"github.com/weak-head/data-pipe/pkg/failure"
 )
 
 func TestProcessorCreation(t *testing.T) {
@@ -53,6 +66,8 @@ func TestProcessorFlow(t *testing.T) {
 		"fails to process the page if convertion fails": testFailsOnConvertionError,
 		"fails to process the page if storage fails":    testFailsOnStorageError,
 		"process keeps the original frame info": testKeepsOriginalInfo,
+		"keeps classified convertion errors":    testKeepsClassifiedConvertionError,
+		"fails on the frame without location":   testFailsOnMissingLocation,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			converter := &converterMock{
@@ -71,7 +86,7 @@ func TestProcessorFlow(t *testing.T) {
 			processor, err := NewProcessor(
 				config,
 				converter,
-				storage,
+				Storages{api.Location_MINIO: storage},
 				log,
 			)
 			require.NotNil(t, processor)
@@ -107,7 +122,7 @@ type storageMock struct {
 	storeErr    error
 }
 
-func (s *storageMock) Store(ctx context.Context, bucket string, objectName string, objectBytes []byte, contentType string) error {
+func (s *storageMock) Store(ctx context.Context, bucket string, objectName string, objectBytes []byte, info storage.ObjectInfo) error {
 	if s.storeErr != nil {
 		return s.storeErr
 	}
@@ -131,7 +146,7 @@ func testFailsIfNoConverter(
 	processor, err := NewProcessor(
 		ProcessorConfig{},
 		nil,
-		s,
+		Storages{api.Location_MINIO: s},
 		l,
 	)
 	require.Nil(t, processor)
@@ -189,7 +204,8 @@ func testFailsOnConvertionError(
 	processedFrame, err := p.Process(context.Background(), frame)
 
 	require.Nil(t, processedFrame)
-	require.Equal(t, e.err, err)
+	require.True(t, errors.Is(err, e.err))
+	require.Equal(t, failure.KindRejected, failure.KindOf(err))
 
 	require.Equal(t, logrus.ErrorLevel, h.LastEntry().Level)
 	require.Equal(t, "Failed to convert data frame.", h.LastEntry().Message)
@@ -234,5 +250,486 @@ func testKeepsOriginalInfo(
 	require.Equal(t, frame.FrameLocation.ObjectName, converted.FrameLocation.ObjectName)
 
 	require.Equal(t, config.DestinationBucket, converted.ConvertedLocation.Bucket)
-	require.Equal(t, getBlobObjectName(frame), converted.ConvertedLocation.ObjectName)
+	require.Equal(t, "converted_"+frame.FrameId+".blob", converted.ConvertedLocation.ObjectName)
+}
+
+func testKeepsClassifiedConvertionError(
+	t *testing.T,
+	e *converterMock,
+	s *storageMock,
+	h *logtest.Hook,
+	config *ProcessorConfig,
+	frame *api.InputFrame,
+	p *processor,
+) {
+	e.err = failure.Corrupt(errors.New("malformed header"))
+
+	_, err := p.Process(context.Background(), frame)
+
+	require.Equal(t, e.err, err)
+	require.Equal(t, failure.KindCorrupt, failure.KindOf(err))
+}
+
+func testFailsOnMissingLocation(
+	t *testing.T,
+	e *converterMock,
+	s *storageMock,
+	h *logtest.Hook,
+	config *ProcessorConfig,
+	frame *api.InputFrame,
+	p *processor,
+) {
+	frame.FrameLocation = nil
+
+	processedFrame, err := p.Process(context.Background(), frame)
+
+	require.Nil(t, processedFrame)
+	require.Equal(t, ErrNoFrameLocation, err)
+	require.Equal(t, failure.KindCorrupt, failure.KindOf(err))
+}
+
+func TestProcessorStreaming(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		s *streamStorageMock,
+		frame *api.InputFrame,
+		newProcessor func(c convert.Converter, s Storage) *processor,
+	){
+		"streams the data frame":                  testStreamsDataFrame,
+		"rejects the failed stream conversion":    testRejectsFailedStreamConversion,
+		"fails if the stream could not be stored": testFailsOnStreamStoreError,
+		"buffers the data frame if not supported": testBuffersWithoutStreamStorage,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			storage := &streamStorageMock{
+				objects: map[string][]byte{"doc11": []byte("frame")},
+			}
+			log, _ := logger.NewNullLogger()
+
+			frame := &api.InputFrame{
+				FrameId: "frame_1",
+				FrameLocation: &api.Location{
+					Kind:       api.Location_MINIO,
+					Bucket:     "bucket_11",
+					ObjectName: "doc11",
+				},
+			}
+
+			fn(t, storage, frame, func(c convert.Converter, s Storage) *processor {
+				p, err := NewProcessor(ProcessorConfig{DestinationBucket: "destination_bucket"}, c, Storages{api.Location_MINIO: s}, log)
+				require.NoError(t, err)
+				return p
+			})
+		})
+	}
+}
+
+// streamStorageMock is an in-memory storage that streams the objects.
+type streamStorageMock struct {
+	objects        map[string][]byte
+	stored         map[string][]byte
+	storeCount     int
+	storeStreamErr error
+}
+
+func (s *streamStorageMock) Store(ctx context.Context, bucket string, objectName string, objectBytes []byte, info storage.ObjectInfo) error {
+	s.storeCount++
+	s.store(objectName, objectBytes)
+	return nil
+}
+
+func (s *streamStorageMock) Retrieve(ctx context.Context, bucket string, objectName string) ([]byte, error) {
+	return s.objects[objectName], nil
+}
+
+func (s *streamStorageMock) StoreStream(ctx context.Context, bucket string, objectName string, r io.Reader, info storage.ObjectInfo) error {
+	if s.storeStreamErr != nil {
+		return s.storeStreamErr
+	}
+
+	objectBytes, err := io.ReadAll(r)
+	if err != nil {
+		return failure.Transient(err)
+	}
+	s.store(objectName, objectBytes)
+	return nil
+}
+
+func (s *streamStorageMock) RetrieveStream(ctx context.Context, bucket string, objectName string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(s.objects[objectName])), nil
+}
+
+func (s *streamStorageMock) store(objectName string, objectBytes []byte) {
+	if s.stored == nil {
+		s.stored = make(map[string][]byte)
+	}
+	s.stored[objectName] = objectBytes
+}
+
+// streamConverterMock is a stream converter that fails after the data frame is copied.
+type streamConverterMock struct {
+	converterMock
+	streamErr error
+}
+
+func (c *streamConverterMock) ConvertStream(ctx context.Context, from io.Reader, to io.Writer) error {
+	if _, err := io.Copy(to, from); err != nil {
+		return err
+	}
+	return c.streamErr
+}
+
+func testStreamsDataFrame(
+	t *testing.T,
+	s *streamStorageMock,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter, s Storage) *processor,
+) {
+	p := newProcessor(&streamConverterMock{}, s)
+
+	blob, err := p.Process(context.Background(), frame)
+	require.NoError(t, err)
+
+	require.Equal(t, 0, s.storeCount)
+	require.Equal(t, []byte("frame"), s.stored[blob.ConvertedLocation.ObjectName])
+}
+
+func testRejectsFailedStreamConversion(
+	t *testing.T,
+	s *streamStorageMock,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter, s Storage) *processor,
+) {
+	convertErr := errors.New("malformed record")
+	p := newProcessor(&streamConverterMock{streamErr: convertErr}, s)
+
+	_, err := p.Process(context.Background(), frame)
+
+	require.True(t, errors.Is(err, convertErr))
+	require.Equal(t, failure.KindRejected, failure.KindOf(err))
+	require.Empty(t, s.stored)
+}
+
+func testFailsOnStreamStoreError(
+	t *testing.T,
+	s *streamStorageMock,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter, s Storage) *processor,
+) {
+	s.storeStreamErr = failure.Transient(errors.New("connection refused"))
+	p := newProcessor(&streamConverterMock{}, s)
+
+	_, err := p.Process(context.Background(), frame)
+
+	require.Equal(t, s.storeStreamErr, err)
+	require.Equal(t, failure.KindTransient, failure.KindOf(err))
+}
+
+func testBuffersWithoutStreamStorage(
+	t *testing.T,
+	s *streamStorageMock,
+	frame *api.InputFrame,
+	newProcessor func(c convert.Converter, s Storage) *processor,
+) {
+	p := newProcessor(&streamConverterMock{}, &storageMock{})
+
+	_, err := p.Process(context.Background(), frame)
+	require.NoError(t, err)
+
+	p = newProcessor(&converterMock{}, s)
+
+	_, err = p.Process(context.Background(), frame)
+	require.NoError(t, err)
+	require.Equal(t, 1, s.storeCount)
+}
+
+func TestProcessorIdempotency(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		st *storage.MemoryStorage,
+		p *processor,
+		converted *int,
+	){
+		"skips the converted data frame":           testSkipsConvertedFrame,
+		"converts the frame with another checksum": testConvertsFrameWithAnotherChecksum,
+		"converts the frame without checksum":      testConvertsFrameWithoutChecksum,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			st := storage.NewMemoryStorage()
+			st.Put("frames", "frame", storage.MemoryObject{Bytes: []byte("frame")})
+
+			converted := 0
+			converter := converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+				converted++
+				return bytes.ToUpper(from), nil
+			})
+
+			p, err := NewProcessor(
+				ProcessorConfig{DestinationBucket: "blobs", Idempotent: true},
+				converter,
+				Storages{api.Location_MINIO: st},
+				nullLogger(),
+			)
+			require.NoError(t, err)
+
+			fn(t, st, p, &converted)
+		})
+	}
+}
+
+func TestProcessorSkipsStreamedBlob(t *testing.T) {
+	st := storage.NewMemoryStorage()
+	st.Put("frames", "frame", storage.MemoryObject{Bytes: []byte("frame")})
+
+	p, err := NewProcessor(
+		ProcessorConfig{DestinationBucket: "blobs", Idempotent: true},
+		&identityConverter{},
+		Storages{api.Location_MINIO: st},
+		nullLogger(),
+	)
+	require.NoError(t, err)
+
+	first, err := p.Process(context.Background(), newFrame(sha256Hex("frame")))
+	require.NoError(t, err)
+	require.Equal(t, sha256Hex("frame"), first.Checksum)
+
+	st.RetrieveHook = func(ctx context.Context, bucket string, objectName string) error {
+		return errors.New("data frame should not be retrieved")
+	}
+
+	second, err := p.Process(context.Background(), newFrame(sha256Hex("frame")))
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+}
+
+func TestProcessorSkipsFilesystemBlob(t *testing.T) {
+	for scenario, c := range map[string]convert.Converter{
+		"skips the converted blob": bytesConverter,
+		"skips the streamed blob":  &identityConverter{},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			root := t.TempDir()
+			require.NoError(t, os.Mkdir(filepath.Join(root, "frames"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(root, "frames", "frame"), []byte("frame"), 0644))
+
+			fs, err := storage.NewFilesystemStorage(
+				storage.FilesystemConfig{Root: root, CreateBucketIfNotExist: true},
+				nullLogger(),
+			)
+			require.NoError(t, err)
+
+			p, err := NewProcessor(
+				ProcessorConfig{DestinationBucket: "blobs", DestinationKind: "filesystem", Idempotent: true},
+				c,
+				Storages{api.Location_FILESYSTEM: fs},
+				nullLogger(),
+			)
+			require.NoError(t, err)
+
+			frame := newFrame(sha256Hex("frame"))
+			frame.FrameLocation.Kind = api.Location_FILESYSTEM
+
+			first, err := p.Process(context.Background(), frame)
+			require.NoError(t, err)
+			require.NotEmpty(t, first.Checksum)
+
+			// The data frame is not retrieved again, once the blob is converted.
+			require.NoError(t, os.Remove(filepath.Join(root, "frames", "frame")))
+
+			second, err := p.Process(context.Background(), frame)
+			require.NoError(t, err)
+			require.Equal(t, first, second)
+		})
+	}
+}
+
+func newFrame(checksum string) *api.InputFrame {
+	return newFrameAt("frames", "frame", checksum)
+}
+
+// newFrameAt returns the data frame, that is stored in the bucket with the object name.
+func newFrameAt(bucket string, objectName string, checksum string) *api.InputFrame {
+	return &api.InputFrame{
+		FrameId:       "frame",
+		FrameLocation: &api.Location{Bucket: bucket, ObjectName: objectName},
+		Checksum:      checksum,
+	}
+}
+
+// testProcessorOption changes the processor, that is created for the test.
+type testProcessorOption func(o *testProcessorOptions)
+
+// testProcessorOptions are the configuration and dependencies
+// of the processor, that is created for the test.
+type testProcessorOptions struct {
+	config    ProcessorConfig
+	converter convert.Converter
+	storages  Storages
+	now       func() time.Time
+}
+
+// withConfig sets the processor configuration.
+// The "blobs" destination bucket is used, if the bucket is not set.
+func withConfig(config ProcessorConfig) testProcessorOption {
+	return func(o *testProcessorOptions) {
+		o.config = config
+	}
+}
+
+// withConverter sets the converter of the data frames.
+func withConverter(c convert.Converter) testProcessorOption {
+	return func(o *testProcessorOptions) {
+		o.converter = c
+	}
+}
+
+// withStorages sets the storages of the data frames and the converted blobs.
+func withStorages(storages Storages) testProcessorOption {
+	return func(o *testProcessorOptions) {
+		o.storages = storages
+	}
+}
+
+// withNow sets the current time of the processor.
+func withNow(now time.Time) testProcessorOption {
+	return func(o *testProcessorOptions) {
+		o.now = func() time.Time { return now }
+	}
+}
+
+// createTestProcessor creates the processor, that converts the data frames
+// of the minio storage with the identity converter, and stores the converted blobs
+// in the "blobs" bucket of the same storage, unless it is changed by the options.
+func createTestProcessor(st *storage.MemoryStorage, options ...testProcessorOption) (*processor, error) {
+	o := testProcessorOptions{
+		converter: &identityConverter{},
+		storages:  Storages{api.Location_MINIO: st},
+	}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.config.DestinationBucket == "" {
+		o.config.DestinationBucket = "blobs"
+	}
+
+	p, err := NewProcessor(o.config, o.converter, o.storages, nullLogger())
+	if err == nil && o.now != nil {
+		p.now = o.now
+	}
+	return p, err
+}
+
+// newTestProcessor creates the processor for the test (see createTestProcessor),
+// and fails the test, if the processor could not be created.
+func newTestProcessor(t *testing.T, st *storage.MemoryStorage, options ...testProcessorOption) *processor {
+	p, err := createTestProcessor(st, options...)
+	require.NoError(t, err)
+	return p
+}
+
+// processStored processes the data frame, and returns the converted blob
+// and its object in the storage. It fails the test, if the processing has failed.
+func processStored(t *testing.T, p *processor, st *storage.MemoryStorage, frame *api.InputFrame) (*api.ConvertedBlob, storage.MemoryObject) {
+	blob, err := p.Process(context.Background(), frame)
+	require.NoError(t, err)
+
+	object, ok := st.Object(blob.ConvertedLocation.Bucket, blob.ConvertedLocation.ObjectName)
+	require.True(t, ok)
+	return blob, object
+}
+
+func testSkipsConvertedFrame(t *testing.T, st *storage.MemoryStorage, p *processor, converted *int) {
+	first, err := p.Process(context.Background(), newFrame(sha256Hex("frame")))
+	require.NoError(t, err)
+
+	st.RetrieveHook = func(ctx context.Context, bucket string, objectName string) error {
+		return errors.New("data frame should not be retrieved")
+	}
+
+	second, err := p.Process(context.Background(), newFrame(sha256Hex("frame")))
+	require.NoError(t, err)
+
+	require.Equal(t, 1, *converted)
+	require.Equal(t, first, second)
+	require.Equal(t, int64(len("frame")), second.FrameSizeBytes)
+}
+
+func testConvertsFrameWithAnotherChecksum(t *testing.T, st *storage.MemoryStorage, p *processor, converted *int) {
+	st.Put("blobs", "converted_frame.blob", storage.MemoryObject{
+		ObjectInfo: storage.ObjectInfo{Metadata: map[string]string{MetadataFrameChecksum: sha256Hex("another frame")}},
+		Bytes:      []byte("ANOTHER FRAME"),
+	})
+
+	blob, err := p.Process(context.Background(), newFrame(sha256Hex("frame")))
+	require.NoError(t, err)
+
+	require.Equal(t, 1, *converted)
+	require.Equal(t, sha256Hex("FRAME"), blob.Checksum)
+}
+
+func testConvertsFrameWithoutChecksum(t *testing.T, st *storage.MemoryStorage, p *processor, converted *int) {
+	_, err := p.Process(context.Background(), newFrame(""))
+	require.NoError(t, err)
+
+	_, err = p.Process(context.Background(), newFrame(""))
+	require.NoError(t, err)
+
+	require.Equal(t, 2, *converted)
+}
+
+func TestProcessorTracing(t *testing.T) {
+	for scenario, tc := range map[string]struct {
+		converter convert.Converter
+		stages    []string
+	}{
+		"traces the conversion": {
+			converter: bytesConverter,
+			stages:    []string{"processor.retrieve", "processor.convert", "processor.store"},
+		},
+		"traces the streaming conversion": {
+			converter: &identityConverter{},
+			stages:    []string{"processor.retrieve", "processor.convert", "processor.store", "processor.update"},
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			rec := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+			defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+			st := storage.NewMemoryStorage()
+			st.Put("frames", "frame", storage.MemoryObject{Bytes: []byte(`{"frame": 1}`)})
+
+			processStored(t, newTestProcessor(t, st, withConverter(tc.converter)), st, newFrame(sha256Hex(`{"frame": 1}`)))
+
+			spans := make(map[string]sdktrace.ReadOnlySpan)
+			for _, s := range rec.Ended() {
+				spans[s.Name()] = s
+			}
+			require.Len(t, spans, len(tc.stages)+1)
+
+			process := spans["processor.Process"]
+			require.NotNil(t, process)
+			for _, name := range tc.stages {
+				require.Contains(t, spans, name)
+				require.Equal(t, process.SpanContext().SpanID(), spans[name].Parent().SpanID(), name)
+			}
+		})
+	}
+}
+
+func TestProcessorTracesFailures(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	st := storage.NewMemoryStorage()
+	p := newTestProcessor(t, st, withConverter(bytesConverter))
+
+	_, err := p.Process(context.Background(), newFrameAt("frames", "missing", ""))
+	require.Error(t, err)
+
+	for _, s := range rec.Ended() {
+		require.Equal(t, codes.Error, s.Status().Code, s.Name())
+	}
+	require.Len(t, rec.Ended(), 2)
 }
@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+var (
+	// ErrUnsupportedLocation happens when no storage
+	// is provided for the kind of the data frame location.
+	ErrUnsupportedLocation = failure.Corrupt(errors.New("unsupported location kind"))
+
+	// ErrNoDestinationStorage happens when no storage
+	// is provided for the destination location kind.
+	ErrNoDestinationStorage = errors.New("no destination storage provided")
+
+	// ErrUnknownLocationKind happens when the location kind is not known.
+	ErrUnknownLocationKind = errors.New("unknown location kind")
+)
+
+// Storages routes the storage operations by the location kind.
+type Storages map[api.Location_Kind]Storage
+
+// Route returns the storage of the location kind.
+// It returns an error if no storage is provided for the location kind.
+func (s Storages) Route(kind api.Location_Kind) (Storage, error) {
+	storage, ok := s[kind]
+	if !ok || storage == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLocation, kind)
+	}
+	return storage, nil
+}
+
+// ParseLocationKind returns the location kind by its case-insensitive name,
+// e.g. "minio" or "filesystem".
+func ParseLocationKind(name string) (api.Location_Kind, error) {
+	kind, ok := api.Location_Kind_value[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownLocationKind, name)
+	}
+	return api.Location_Kind(kind), nil
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	api "github.com/weak-head/data-pipe/api/v1"
+	"github.com/weak-head/data-pipe/internal/storage"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestStorageRouting(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		minio *storage.MemoryStorage,
+		filesystem *storage.MemoryStorage,
+	){
+		"routes by the frame and destination kind":  testRoutesByKind,
+		"fails on the unsupported frame kind":       testFailsOnUnsupportedKind,
+		"fails to create without destination":       testFailsWithoutDestination,
+		"fails to create with unknown destination":  testFailsOnUnknownDestination,
+		"uses minio destination if kind is not set": testUsesMinioDestinationByDefault,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			fn(t, storage.NewMemoryStorage(), storage.NewMemoryStorage())
+		})
+	}
+}
+
+func testRoutesByKind(t *testing.T, minio *storage.MemoryStorage, filesystem *storage.MemoryStorage) {
+	minio.Put("frames", "frame", storage.MemoryObject{Bytes: []byte("frame")})
+
+	p := newTestProcessor(t, minio,
+		withConfig(ProcessorConfig{DestinationKind: "filesystem"}),
+		withStorages(Storages{
+			api.Location_MINIO:      minio,
+			api.Location_FILESYSTEM: filesystem,
+		}),
+	)
+
+	blob, err := p.Process(context.Background(), &api.InputFrame{
+		FrameId:       "frame",
+		FrameLocation: &api.Location{Kind: api.Location_MINIO, Bucket: "frames", ObjectName: "frame"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, api.Location_MINIO, blob.FrameLocation.Kind)
+	require.Equal(t, api.Location_FILESYSTEM, blob.ConvertedLocation.Kind)
+	require.Empty(t, minio.Objects("blobs"))
+	require.Equal(t, []string{blob.ConvertedLocation.ObjectName}, filesystem.Objects("blobs"))
+}
+
+func testFailsOnUnsupportedKind(t *testing.T, minio *storage.MemoryStorage, filesystem *storage.MemoryStorage) {
+	_, err := newTestProcessor(t, minio).Process(context.Background(), &api.InputFrame{
+		FrameId:       "frame",
+		FrameLocation: &api.Location{Kind: api.Location_FILESYSTEM, Bucket: "frames", ObjectName: "frame"},
+	})
+	require.True(t, errors.Is(err, ErrUnsupportedLocation))
+	require.True(t, failure.IsPermanent(err))
+}
+
+func testFailsWithoutDestination(t *testing.T, minio *storage.MemoryStorage, filesystem *storage.MemoryStorage) {
+	_, err := createTestProcessor(minio, withConfig(ProcessorConfig{DestinationKind: "filesystem"}))
+	require.True(t, errors.Is(err, ErrNoDestinationStorage))
+}
+
+func testFailsOnUnknownDestination(t *testing.T, minio *storage.MemoryStorage, filesystem *storage.MemoryStorage) {
+	_, err := createTestProcessor(minio, withConfig(ProcessorConfig{DestinationKind: "s3"}))
+	require.True(t, errors.Is(err, ErrUnknownLocationKind))
+}
+
+func testUsesMinioDestinationByDefault(t *testing.T, minio *storage.MemoryStorage, filesystem *storage.MemoryStorage) {
+	p := newTestProcessor(t, minio)
+	require.Equal(t, api.Location_MINIO, p.destinationKind)
+
+	kind, err := ParseLocationKind("FileSystem")
+	require.NoError(t, err)
+	require.Equal(t, api.Location_FILESYSTEM, kind)
+}
@@ -1,30 +1,52 @@
 package sleeper
 
 import (
+	"context"
 	"time"
 )
 
-// exponentialBackoffSleeper
+// exponentialBackoffSleeper doubles the sleep duration on each sleep,
+// up to the maximum sleep duration. The sleeper keeps the state
+// of a single attempt loop, and is not safe for concurrent use.
 type exponentialBackoffSleeper struct {
 	initial       time.Duration
+	max           time.Duration
 	sleepDuration time.Duration
 }
 
-// NewExponentialSleeper
-func NewExponentialSleeper(initial time.Duration) (*exponentialBackoffSleeper, error) {
+// NewExponentialSleeper creates a new sleeper with the initial
+// and the maximum sleep duration.
+func NewExponentialSleeper(initial time.Duration, max time.Duration) *exponentialBackoffSleeper {
+	if max < initial {
+		max = initial
+	}
+
 	return &exponentialBackoffSleeper{
 		initial:       initial,
+		max:           max,
 		sleepDuration: initial,
-	}, nil
+	}
 }
 
-// Sleep
-func (e *exponentialBackoffSleeper) Sleep() {
-	time.Sleep(e.sleepDuration)
+// Sleep sleeps for the current sleep duration,
+// and returns early once the context is done.
+func (e *exponentialBackoffSleeper) Sleep(ctx context.Context) {
+	d := e.sleepDuration
 	e.sleepDuration += e.sleepDuration
+	if e.sleepDuration > e.max {
+		e.sleepDuration = e.max
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
 }
 
-// Reset
+// Reset resets the sleep duration to the initial one.
 func (e *exponentialBackoffSleeper) Reset() {
 	e.sleepDuration = e.initial
 }
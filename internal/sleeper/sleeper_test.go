@@ -1 +1,56 @@
 package sleeper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialSleeper(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"doubles the sleep up to the maximum": testDoublesUpToMaximum,
+		"resets the sleep to the initial one": testResetsSleep,
+		"returns once the context is done":    testReturnsOnContext,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func testDoublesUpToMaximum(t *testing.T) {
+	s := NewExponentialSleeper(time.Millisecond, 4*time.Millisecond)
+
+	var durations []time.Duration
+	for i := 0; i < 5; i++ {
+		durations = append(durations, s.sleepDuration)
+		s.Sleep(context.Background())
+	}
+
+	require.Equal(t, []time.Duration{
+		time.Millisecond,
+		2 * time.Millisecond,
+		4 * time.Millisecond,
+		4 * time.Millisecond,
+		4 * time.Millisecond,
+	}, durations)
+}
+
+func testResetsSleep(t *testing.T) {
+	s := NewExponentialSleeper(time.Millisecond, time.Second)
+	s.Sleep(context.Background())
+	s.Sleep(context.Background())
+
+	s.Reset()
+	require.Equal(t, time.Millisecond, s.sleepDuration)
+}
+
+func testReturnsOnContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := NewExponentialSleeper(time.Hour, time.Hour)
+	started := time.Now()
+	s.Sleep(ctx)
+	require.Less(t, time.Since(started), time.Second)
+}
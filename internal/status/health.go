@@ -1,11 +1,15 @@
 package status
 
 import (
+	"context"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/weak-head/data-pipe/internal/validation"
 )
 
 // Config
@@ -13,9 +17,17 @@ type Config struct {
 	RpcAddr string
 }
 
+// Validate returns the aggregated validation failures of the status configuration.
+func (c Config) Validate() error {
+	var errs validation.Errors
+	errs.Add("rpcaddr", validation.Addr(c.RpcAddr))
+	return errs.Err()
+}
+
 // statusServer
 type statusServer struct {
 	grpcServer *grpc.Server
+	health     *health.Server
 }
 
 // NewStatusServer
@@ -28,6 +40,7 @@ func NewStatusServer() (*statusServer, error) {
 
 	return &statusServer{
 		grpcServer: grpcServer,
+		health:     health_service,
 	}, nil
 }
 
@@ -49,3 +62,31 @@ func (s *statusServer) Serve(config Config) error {
 func (s *statusServer) Stop() {
 	s.grpcServer.Stop()
 }
+
+// Watch reports the service as serving while the check passes.
+// The check is done on each interval until the context is canceled,
+// and then the service is reported as not serving.
+func (s *statusServer) Watch(ctx context.Context, service string, interval time.Duration, check func() bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.setServing(service, check())
+
+		select {
+		case <-ctx.Done():
+			s.setServing(service, false)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setServing sets the serving status of the service.
+func (s *statusServer) setServing(service string, serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	s.health.SetServingStatus(service, status)
+}
@@ -1 +1,66 @@
 package status
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestStatusServer(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, s *statusServer){
+		"reports the watched service status":     testReportsWatchedStatus,
+		"stops serving once watching is stopped": testStopsServing,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			s, err := NewStatusServer()
+			require.NoError(t, err)
+			fn(t, s)
+		})
+	}
+}
+
+func servingStatus(t *testing.T, s *statusServer, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := s.health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func testReportsWatchedStatus(t *testing.T, s *statusServer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var healthy int32 = 1
+	go s.Watch(ctx, "pipeline", time.Millisecond, func() bool { return atomic.LoadInt32(&healthy) == 1 })
+
+	require.Eventually(t, func() bool {
+		return servingStatus(t, s, "pipeline") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, time.Millisecond)
+
+	atomic.StoreInt32(&healthy, 0)
+	require.Eventually(t, func() bool {
+		return servingStatus(t, s, "pipeline") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, time.Millisecond)
+
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, s, ""))
+}
+
+func testStopsServing(t *testing.T, s *statusServer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Watch(ctx, "pipeline", time.Hour, func() bool { return true })
+	}()
+
+	require.Eventually(t, func() bool {
+		return servingStatus(t, s, "pipeline") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, s, "pipeline"))
+}
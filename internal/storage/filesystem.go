@@ -0,0 +1,415 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/validation"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+var (
+	// ErrInvalidObjectPath happens when the bucket or the object name
+	// is not a valid path inside of the filesystem storage root.
+	ErrInvalidObjectPath = failure.Corrupt(errors.New("invalid object path"))
+)
+
+// FilesystemConfig
+type FilesystemConfig struct {
+	// Root directory of the storage,
+	// each bucket is a directory in the root directory.
+	Root string
+
+	CreateBucketIfNotExist bool
+}
+
+// Validate returns the aggregated validation failures of the filesystem configuration.
+func (c FilesystemConfig) Validate() error {
+	var errs validation.Errors
+	errs.Add("root", validation.Required(c.Root))
+	return errs.Err()
+}
+
+// filesystemStorage stores the objects in a local directory tree.
+// The bucket is a directory in the root directory,
+// and the object name is a file path in the bucket directory.
+// The content type, encoding and metadata of the object are stored
+// in the hidden info file next to the object file, e.g. ".frame.bin.info".
+type filesystemStorage struct {
+	config FilesystemConfig
+
+	log logger.Log
+}
+
+// NewFilesystemStorage creates a new storage in the root directory.
+// It returns an error if the root directory doesn't exist.
+func NewFilesystemStorage(conf FilesystemConfig, log logger.Log) (*filesystemStorage, error) {
+	l := log.WithFields(logger.Fields{
+		logger.FieldPackage:  "storage",
+		logger.FieldFunction: "NewFilesystemStorage",
+	})
+
+	info, err := os.Stat(conf.Root)
+	if err != nil {
+		l.Error(err, "Failed to access the storage root directory.")
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		err := &os.PathError{Op: "open", Path: conf.Root, Err: errors.New("not a directory")}
+		l.Error(err, "Storage root is not a directory.")
+		return nil, err
+	}
+
+	l.Info("Created a new filesystem storage.")
+
+	return &filesystemStorage{
+		config: conf,
+		log:    l,
+	}, nil
+}
+
+// Store stores the object atomically, so the partially written object
+// is never observed by the readers.
+func (f *filesystemStorage) Store(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	objectBytes []byte,
+	info ObjectInfo,
+) error {
+	log := f.log.WithFields(logger.Fields{
+		logger.FieldFunction: "filesystemStorage.Store",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	if err := f.store(ctx, bucket, objectName, bytes.NewReader(objectBytes), info); err != nil {
+		log.Error(err, "Failed to store the object.")
+		return err
+	}
+
+	log.Info("Stored a new object to the storage.")
+	return nil
+}
+
+// StoreStream stores the object that is read from the reader until EOF.
+// The object is not stored if the reader fails.
+func (f *filesystemStorage) StoreStream(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	r io.Reader,
+	info ObjectInfo,
+) error {
+	log := f.log.WithFields(logger.Fields{
+		logger.FieldFunction: "filesystemStorage.StoreStream",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	if err := f.store(ctx, bucket, objectName, r, info); err != nil {
+		log.Error(err, "Failed to store the object stream.")
+		return err
+	}
+
+	log.Info("Stored a new object stream to the storage.")
+	return nil
+}
+
+// Retrieve
+func (f *filesystemStorage) Retrieve(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) ([]byte, error) {
+	log := f.log.WithFields(logger.Fields{
+		logger.FieldFunction: "filesystemStorage.Retrieve",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	r, err := f.open(ctx, bucket, objectName)
+	if err != nil {
+		log.Error(err, "Failed to open the object.")
+		return nil, err
+	}
+	defer r.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r); err != nil {
+		log.Error(err, "Failed to read the object from the storage.")
+		return nil, err
+	}
+
+	log.Info("Retrieved the object from the storage.")
+	return buf.Bytes(), nil
+}
+
+// RetrieveStream returns the reader of the object.
+// The caller should close the returned reader.
+func (f *filesystemStorage) RetrieveStream(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) (io.ReadCloser, error) {
+	log := f.log.WithFields(logger.Fields{
+		logger.FieldFunction: "filesystemStorage.RetrieveStream",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	r, err := f.open(ctx, bucket, objectName)
+	if err != nil {
+		log.Error(err, "Failed to open the object.")
+		return nil, err
+	}
+
+	log.Info("Retrieved the object stream from the storage.")
+	return r, nil
+}
+
+// Stat returns the description of the stored object.
+// The object that is stored without the info file is described by its size only.
+func (f *filesystemStorage) Stat(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) (ObjectStat, error) {
+	_, objectPath, err := f.path(bucket, objectName)
+	if err != nil {
+		return ObjectStat{}, err
+	}
+
+	info, err := os.Stat(objectPath)
+	if err != nil {
+		return ObjectStat{}, classifyOpen(ctx, err)
+	}
+
+	if info.IsDir() {
+		return ObjectStat{}, failure.NotFound(&os.PathError{Op: "stat", Path: objectPath, Err: errors.New("is a directory")})
+	}
+
+	objectInfo, err := readInfo(ctx, infoPath(objectPath))
+	if err != nil {
+		return ObjectStat{}, err
+	}
+
+	return ObjectStat{ObjectInfo: objectInfo, SizeBytes: info.Size()}, nil
+}
+
+// UpdateInfo replaces the content type, encoding and metadata of the stored object.
+func (f *filesystemStorage) UpdateInfo(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	info ObjectInfo,
+) error {
+	log := f.log.WithFields(logger.Fields{
+		logger.FieldFunction: "filesystemStorage.UpdateInfo",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	if _, err := f.Stat(ctx, bucket, objectName); err != nil {
+		log.Error(err, "Failed to stat the object.")
+		return err
+	}
+
+	_, objectPath, err := f.path(bucket, objectName)
+	if err != nil {
+		return err
+	}
+
+	if err := writeInfo(ctx, infoPath(objectPath), info); err != nil {
+		log.Error(err, "Failed to update the object info.")
+		return err
+	}
+
+	log.Info("Updated the object info.")
+	return nil
+}
+
+// store writes the object and then its info file.
+// The info file of the replaced object is removed before the object is written,
+// so the object is never described by the info of the replaced object.
+func (f *filesystemStorage) store(ctx context.Context, bucket string, objectName string, r io.Reader, info ObjectInfo) error {
+	bucketPath, objectPath, err := f.path(bucket, objectName)
+	if err != nil {
+		return err
+	}
+
+	if f.config.CreateBucketIfNotExist {
+		if err := os.MkdirAll(bucketPath, 0755); err != nil {
+			return classify(ctx, err)
+		}
+	} else if _, err := os.Stat(bucketPath); err != nil {
+		return classify(ctx, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return classify(ctx, err)
+	}
+
+	if err := os.Remove(infoPath(objectPath)); err != nil && !os.IsNotExist(err) {
+		return classify(ctx, err)
+	}
+
+	if err := writeFile(ctx, objectPath, r); err != nil {
+		return err
+	}
+
+	return writeInfo(ctx, infoPath(objectPath), info)
+}
+
+// writeFile writes the file atomically: the content is written to a temporary file
+// in the same directory, and then the temporary file is renamed to the file.
+// The file is readable by everyone, unlike the temporary file.
+func writeFile(ctx context.Context, path string, r io.Reader) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return classify(ctx, err)
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, &contextReader{ctx: ctx, r: r}); err != nil {
+		return classify(ctx, err)
+	}
+
+	if err := tmp.Chmod(0644); err != nil {
+		return classify(ctx, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return classify(ctx, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return classify(ctx, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return classify(ctx, err)
+	}
+
+	committed = true
+	return nil
+}
+
+// infoPath returns the path of the info file of the object.
+func infoPath(objectPath string) string {
+	return filepath.Join(filepath.Dir(objectPath), "."+filepath.Base(objectPath)+".info")
+}
+
+// writeInfo writes the object info file atomically.
+func writeInfo(ctx context.Context, path string, info ObjectInfo) error {
+	b, err := encodeInfo(info)
+	if err != nil {
+		return err
+	}
+	return writeFile(ctx, path, bytes.NewReader(b))
+}
+
+// readInfo reads the object info file.
+// The missing info file describes nothing.
+func readInfo(ctx context.Context, path string) (ObjectInfo, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ObjectInfo{}, nil
+	}
+	if err != nil {
+		return ObjectInfo{}, classify(ctx, err)
+	}
+
+	return decodeInfo(b)
+}
+
+// open opens the object file.
+// The missing bucket or object is a permanent failure.
+func (f *filesystemStorage) open(ctx context.Context, bucket string, objectName string) (io.ReadCloser, error) {
+	_, objectPath, err := f.path(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, classifyOpen(ctx, err)
+	}
+
+	return &contextReader{ctx: ctx, r: file, c: file}, nil
+}
+
+// path returns the paths of the bucket directory and the object file.
+// It returns an error if the paths are outside of the root directory.
+func (f *filesystemStorage) path(bucket string, objectName string) (string, string, error) {
+	if !isLocalPath(bucket) || strings.ContainsAny(bucket, `/\`) || !isLocalPath(objectName) {
+		return "", "", ErrInvalidObjectPath
+	}
+
+	bucketPath := filepath.Join(f.config.Root, bucket)
+	return bucketPath, filepath.Join(bucketPath, filepath.FromSlash(objectName)), nil
+}
+
+// isLocalPath reports whether the path is a non-empty relative path,
+// that doesn't escape the directory it is relative to.
+func isLocalPath(path string) bool {
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "/") {
+		return false
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(path))
+	return cleaned != "." &&
+		cleaned != ".." &&
+		!strings.HasPrefix(cleaned, ".."+string(filepath.Separator))
+}
+
+// classifyOpen classifies the failure to open an object.
+// The missing object or bucket is a permanent failure.
+func classifyOpen(ctx context.Context, err error) error {
+	if os.IsNotExist(err) {
+		return failure.NotFound(err)
+	}
+	return classify(ctx, err)
+}
+
+// contextReader stops reading once the context is done,
+// and classifies the read failures.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+	c   io.Closer
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, failure.Canceled(err)
+	}
+
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF && failure.KindOf(err) == failure.KindUnknown {
+		err = classify(r.ctx, err)
+	}
+	return n, err
+}
+
+func (r *contextReader) Close() error {
+	if r.c == nil {
+		return nil
+	}
+	return r.c.Close()
+}
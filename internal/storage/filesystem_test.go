@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestFilesystemStorage(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		t *testing.T,
+		root string,
+		s *filesystemStorage,
+	){
+		"stores and retrieves the object":          testFilesystemStoresObject,
+		"stores and retrieves the object stream":   testFilesystemStoresObjectStream,
+		"fails to retrieve the missing object":     testFilesystemMissingObject,
+		"fails to store without bucket":            testFilesystemMissingBucket,
+		"rejects the object outside of the bucket": testFilesystemRejectsInvalidPath,
+		"doesn't store the failed object stream":   testFilesystemFailedStream,
+		"stores the object info":                   testFilesystemStoresInfo,
+		"replaces the info of the replaced object": testFilesystemReplacesInfo,
+		"updates the object info":                  testFilesystemUpdatesInfo,
+		"stores the files readable by everyone":    testFilesystemFileMode,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			root := t.TempDir()
+			require.NoError(t, os.Mkdir(filepath.Join(root, "frames"), 0755))
+			log, _ := logger.NewNullLogger()
+
+			s, err := NewFilesystemStorage(FilesystemConfig{Root: root}, log)
+			require.NoError(t, err)
+
+			fn(t, root, s)
+		})
+	}
+}
+
+func TestFilesystemStorageCreation(t *testing.T) {
+	log, _ := logger.NewNullLogger()
+
+	_, err := NewFilesystemStorage(FilesystemConfig{Root: filepath.Join(t.TempDir(), "missing")}, log)
+	require.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+
+	_, err = NewFilesystemStorage(FilesystemConfig{Root: file}, log)
+	require.Error(t, err)
+}
+
+func testFilesystemStoresObject(t *testing.T, root string, s *filesystemStorage) {
+	ctx := context.Background()
+	require.NoError(t, s.Store(ctx, "frames", "2021/frame.bin", []byte("frame"), ObjectInfo{ContentType: "application/octet-stream"}))
+
+	objectBytes, err := s.Retrieve(ctx, "frames", "2021/frame.bin")
+	require.NoError(t, err)
+	require.Equal(t, []byte("frame"), objectBytes)
+
+	onDisk, err := os.ReadFile(filepath.Join(root, "frames", "2021", "frame.bin"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("frame"), onDisk)
+
+	stat, err := s.Stat(ctx, "frames", "2021/frame.bin")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stat.SizeBytes)
+	require.Equal(t, "application/octet-stream", stat.ContentType)
+}
+
+func testFilesystemStoresObjectStream(t *testing.T, root string, s *filesystemStorage) {
+	ctx := context.Background()
+	require.NoError(t, s.StoreStream(ctx, "frames", "frame.bin", bytes.NewReader([]byte("frame")), ObjectInfo{}))
+
+	r, err := s.RetrieveStream(ctx, "frames", "frame.bin")
+	require.NoError(t, err)
+	defer r.Close()
+
+	objectBytes, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte("frame"), objectBytes)
+}
+
+func testFilesystemMissingObject(t *testing.T, root string, s *filesystemStorage) {
+	_, err := s.Retrieve(context.Background(), "frames", "missing.bin")
+	require.Equal(t, failure.KindNotFound, failure.KindOf(err))
+
+	_, err = s.RetrieveStream(context.Background(), "missing", "frame.bin")
+	require.Equal(t, failure.KindNotFound, failure.KindOf(err))
+
+	_, err = s.Stat(context.Background(), "frames", "2021")
+	require.Equal(t, failure.KindNotFound, failure.KindOf(err))
+}
+
+func testFilesystemMissingBucket(t *testing.T, root string, s *filesystemStorage) {
+	err := s.Store(context.Background(), "blobs", "blob.bin", []byte("blob"), ObjectInfo{})
+	require.Error(t, err)
+
+	s.config.CreateBucketIfNotExist = true
+	require.NoError(t, s.Store(context.Background(), "blobs", "blob.bin", []byte("blob"), ObjectInfo{}))
+}
+
+func testFilesystemRejectsInvalidPath(t *testing.T, root string, s *filesystemStorage) {
+	for _, path := range []struct{ bucket, objectName string }{
+		{"frames", "../escaped.bin"},
+		{"frames", "/etc/passwd"},
+		{"frames", ""},
+		{"..", "frame.bin"},
+		{"frames/nested", "frame.bin"},
+	} {
+		err := s.Store(context.Background(), path.bucket, path.objectName, []byte("frame"), ObjectInfo{})
+		require.True(t, errors.Is(err, ErrInvalidObjectPath), path)
+		require.Equal(t, failure.KindCorrupt, failure.KindOf(err))
+	}
+}
+
+func testFilesystemFailedStream(t *testing.T, root string, s *filesystemStorage) {
+	readErr := errors.New("connection reset")
+	err := s.StoreStream(context.Background(), "frames", "frame.bin", iotest.ErrReader(readErr), ObjectInfo{})
+	require.True(t, errors.Is(err, readErr))
+
+	entries, err := os.ReadDir(filepath.Join(root, "frames"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func testFilesystemStoresInfo(t *testing.T, root string, s *filesystemStorage) {
+	ctx := context.Background()
+	info := ObjectInfo{
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Metadata:        map[string]string{"Checksum": "c1"},
+	}
+	require.NoError(t, s.StoreStream(ctx, "frames", "2021/frame.bin", bytes.NewReader([]byte("frame")), info))
+
+	stat, err := s.Stat(ctx, "frames", "2021/frame.bin")
+	require.NoError(t, err)
+	require.Equal(t, ObjectStat{ObjectInfo: info, SizeBytes: 5}, stat)
+
+	_, err = os.Stat(filepath.Join(root, "frames", "2021", ".frame.bin.info"))
+	require.NoError(t, err)
+}
+
+func testFilesystemReplacesInfo(t *testing.T, root string, s *filesystemStorage) {
+	ctx := context.Background()
+	require.NoError(t, s.Store(ctx, "frames", "frame.bin", []byte("frame"), ObjectInfo{
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"Checksum": "c1"},
+	}))
+	require.NoError(t, s.Store(ctx, "frames", "frame.bin", []byte("frame 2"), ObjectInfo{}))
+
+	stat, err := s.Stat(ctx, "frames", "frame.bin")
+	require.NoError(t, err)
+	require.Equal(t, ObjectStat{SizeBytes: 7}, stat)
+
+	// The object that is stored without the info file is described by its size only.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "frames", "other.bin"), []byte("other"), 0644))
+	stat, err = s.Stat(ctx, "frames", "other.bin")
+	require.NoError(t, err)
+	require.Equal(t, ObjectStat{SizeBytes: 5}, stat)
+}
+
+func testFilesystemUpdatesInfo(t *testing.T, root string, s *filesystemStorage) {
+	ctx := context.Background()
+	err := s.UpdateInfo(ctx, "frames", "frame.bin", ObjectInfo{})
+	require.Equal(t, failure.KindNotFound, failure.KindOf(err))
+
+	require.NoError(t, s.Store(ctx, "frames", "frame.bin", []byte("frame"), ObjectInfo{ContentType: "text/plain"}))
+
+	info := ObjectInfo{ContentEncoding: "gzip", Metadata: map[string]string{"Checksum": "c1"}}
+	require.NoError(t, s.UpdateInfo(ctx, "frames", "frame.bin", info))
+
+	stat, err := s.Stat(ctx, "frames", "frame.bin")
+	require.NoError(t, err)
+	require.Equal(t, ObjectStat{ObjectInfo: info, SizeBytes: 5}, stat)
+}
+
+func testFilesystemFileMode(t *testing.T, root string, s *filesystemStorage) {
+	ctx := context.Background()
+	require.NoError(t, s.Store(ctx, "frames", "frame.bin", []byte("frame"), ObjectInfo{ContentType: "text/plain"}))
+	require.NoError(t, s.StoreStream(ctx, "frames", "stream.bin", bytes.NewReader([]byte("frame")), ObjectInfo{}))
+
+	for _, name := range []string{"frame.bin", ".frame.bin.info", "stream.bin"} {
+		info, err := os.Stat(filepath.Join(root, "frames", name))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0644), info.Mode().Perm(), name)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"encoding/json"
+	"path"
+
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+const (
+	// contentTypeInfo is the content type of the object info sidecar.
+	contentTypeInfo = "application/json"
+)
+
+// objectInfoFile is the content of the object info sidecar,
+// that describes the object next to it.
+type objectInfoFile struct {
+	ContentType     string            `json:"contentType,omitempty"`
+	ContentEncoding string            `json:"contentEncoding,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+// infoName returns the name of the info sidecar of the object,
+// e.g. "2021/.frame.bin.info" for the "2021/frame.bin" object.
+func infoName(objectName string) string {
+	return path.Join(path.Dir(objectName), "."+path.Base(objectName)+".info")
+}
+
+// encodeInfo returns the content of the object info sidecar.
+func encodeInfo(info ObjectInfo) ([]byte, error) {
+	return json.Marshal(objectInfoFile{
+		ContentType:     info.ContentType,
+		ContentEncoding: info.ContentEncoding,
+		Metadata:        info.Metadata,
+	})
+}
+
+// decodeInfo decodes the content of the object info sidecar.
+// The sidecar that could not be decoded is corrupt.
+func decodeInfo(b []byte) (ObjectInfo, error) {
+	var info objectInfoFile
+	if err := json.Unmarshal(b, &info); err != nil {
+		return ObjectInfo{}, failure.Corrupt(err)
+	}
+
+	return ObjectInfo{
+		ContentType:     info.ContentType,
+		ContentEncoding: info.ContentEncoding,
+		Metadata:        info.Metadata,
+	}, nil
+}
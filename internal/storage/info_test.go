@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestObjectInfoSidecar(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"names the sidecar next to the object": testNamesInfoSidecar,
+		"encodes and decodes the object info":  testEncodesInfo,
+		"fails on the corrupt sidecar":         testFailsOnCorruptInfo,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func testNamesInfoSidecar(t *testing.T) {
+	require.Equal(t, ".frame.bin.info", infoName("frame.bin"))
+	require.Equal(t, "2021/06/.frame.bin.info", infoName("2021/06/frame.bin"))
+}
+
+func testEncodesInfo(t *testing.T) {
+	info := ObjectInfo{
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Metadata:        map[string]string{"Checksum": "abc", "Size-Bytes": "3"},
+	}
+
+	b, err := encodeInfo(info)
+	require.NoError(t, err)
+
+	decoded, err := decodeInfo(b)
+	require.NoError(t, err)
+	require.Equal(t, info, decoded)
+}
+
+func testFailsOnCorruptInfo(t *testing.T) {
+	_, err := decodeInfo([]byte("{"))
+	require.Equal(t, failure.KindCorrupt, failure.KindOf(err))
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+var (
+	// ErrNoSuchObject happens when the object is not found in the in-memory storage.
+	ErrNoSuchObject = failure.NotFound(errors.New("no such object"))
+)
+
+// MemoryObject is the object of the in-memory storage.
+type MemoryObject struct {
+	ObjectInfo
+
+	Bytes []byte
+}
+
+// MemoryStorage is an in-memory object storage, that is used to run
+// the processors and pipelines without minio, e.g. in the end-to-end tests.
+// The buckets are created once the first object is stored.
+//
+// The hooks are used to inject the failures,
+// and should be set before the storage is used.
+type MemoryStorage struct {
+	// StoreHook is called before the object is stored,
+	// the store fails with the returned error.
+	StoreHook func(ctx context.Context, bucket string, objectName string) error
+
+	// RetrieveHook is called before the object is retrieved,
+	// the retrieval fails with the returned error.
+	RetrieveHook func(ctx context.Context, bucket string, objectName string) error
+
+	mu      sync.Mutex
+	buckets map[string]map[string]MemoryObject
+}
+
+// NewMemoryStorage creates a new empty in-memory storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		buckets: make(map[string]map[string]MemoryObject),
+	}
+}
+
+// Put puts the object to the storage, bypassing the hooks.
+func (m *MemoryStorage) Put(bucket string, objectName string, object MemoryObject) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		objects = make(map[string]MemoryObject)
+		m.buckets[bucket] = objects
+	}
+	object.Bytes = append([]byte(nil), object.Bytes...)
+	object.Metadata = copyMetadata(object.Metadata)
+	objects[objectName] = object
+}
+
+// Object returns the stored object, bypassing the hooks.
+func (m *MemoryStorage) Object(bucket string, objectName string) (MemoryObject, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	object, ok := m.buckets[bucket][objectName]
+	if ok {
+		object.Bytes = append([]byte(nil), object.Bytes...)
+		object.Metadata = copyMetadata(object.Metadata)
+	}
+	return object, ok
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	return copied
+}
+
+// Objects returns the sorted names of the objects in the bucket.
+func (m *MemoryStorage) Objects(bucket string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.buckets[bucket]))
+	for name := range m.buckets[bucket] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Store
+func (m *MemoryStorage) Store(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	objectBytes []byte,
+	info ObjectInfo,
+) error {
+	if m.StoreHook != nil {
+		if err := m.StoreHook(ctx, bucket, objectName); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return failure.Canceled(err)
+	}
+
+	m.Put(bucket, objectName, MemoryObject{ObjectInfo: info, Bytes: objectBytes})
+	return nil
+}
+
+// StoreStream stores the object that is read from the reader until EOF.
+// The object is not stored if the reader fails.
+func (m *MemoryStorage) StoreStream(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	r io.Reader,
+	info ObjectInfo,
+) error {
+	if m.StoreHook != nil {
+		if err := m.StoreHook(ctx, bucket, objectName); err != nil {
+			return err
+		}
+	}
+
+	objectBytes, err := io.ReadAll(r)
+	if err != nil {
+		return classify(ctx, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return failure.Canceled(err)
+	}
+
+	m.Put(bucket, objectName, MemoryObject{ObjectInfo: info, Bytes: objectBytes})
+	return nil
+}
+
+// Retrieve
+func (m *MemoryStorage) Retrieve(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) ([]byte, error) {
+	if m.RetrieveHook != nil {
+		if err := m.RetrieveHook(ctx, bucket, objectName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, failure.Canceled(err)
+	}
+
+	object, ok := m.Object(bucket, objectName)
+	if !ok {
+		return nil, ErrNoSuchObject
+	}
+	return object.Bytes, nil
+}
+
+// Stat returns the description of the stored object.
+func (m *MemoryStorage) Stat(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) (ObjectStat, error) {
+	if err := ctx.Err(); err != nil {
+		return ObjectStat{}, failure.Canceled(err)
+	}
+
+	object, ok := m.Object(bucket, objectName)
+	if !ok {
+		return ObjectStat{}, ErrNoSuchObject
+	}
+	return ObjectStat{ObjectInfo: object.ObjectInfo, SizeBytes: int64(len(object.Bytes))}, nil
+}
+
+// UpdateInfo replaces the content type, encoding and metadata of the stored object.
+func (m *MemoryStorage) UpdateInfo(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	info ObjectInfo,
+) error {
+	if err := ctx.Err(); err != nil {
+		return failure.Canceled(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	object, ok := m.buckets[bucket][objectName]
+	if !ok {
+		return ErrNoSuchObject
+	}
+	object.ObjectInfo = info
+	object.Metadata = copyMetadata(info.Metadata)
+	m.buckets[bucket][objectName] = object
+	return nil
+}
+
+// RetrieveStream returns the reader of the object.
+func (m *MemoryStorage) RetrieveStream(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) (io.ReadCloser, error) {
+	objectBytes, err := m.Retrieve(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(objectBytes)), nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, s *MemoryStorage){
+		"stores and retrieves the object":        testMemoryStoresObject,
+		"stores and retrieves the object stream": testMemoryStoresObjectStream,
+		"fails to retrieve the missing object":   testMemoryMissingObject,
+		"injects the failures with hooks":        testMemoryInjectsFailures,
+		"updates the object info":                testMemoryUpdatesInfo,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			fn(t, NewMemoryStorage())
+		})
+	}
+}
+
+func testMemoryStoresObject(t *testing.T, s *MemoryStorage) {
+	ctx := context.Background()
+	require.NoError(t, s.Store(ctx, "blobs", "blob", []byte("blob"), ObjectInfo{ContentType: "application/octet-stream"}))
+
+	objectBytes, err := s.Retrieve(ctx, "blobs", "blob")
+	require.NoError(t, err)
+	require.Equal(t, []byte("blob"), objectBytes)
+
+	object, ok := s.Object("blobs", "blob")
+	require.True(t, ok)
+	require.Equal(t, "application/octet-stream", object.ContentType)
+	require.Equal(t, []string{"blob"}, s.Objects("blobs"))
+
+	stat, err := s.Stat(ctx, "blobs", "blob")
+	require.NoError(t, err)
+	require.Equal(t, "application/octet-stream", stat.ContentType)
+	require.Equal(t, int64(4), stat.SizeBytes)
+}
+
+func testMemoryStoresObjectStream(t *testing.T, s *MemoryStorage) {
+	ctx := context.Background()
+	require.NoError(t, s.StoreStream(ctx, "blobs", "blob", strings.NewReader("blob"), ObjectInfo{}))
+
+	r, err := s.RetrieveStream(ctx, "blobs", "blob")
+	require.NoError(t, err)
+
+	objectBytes, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte("blob"), objectBytes)
+}
+
+func testMemoryMissingObject(t *testing.T, s *MemoryStorage) {
+	_, err := s.Retrieve(context.Background(), "frames", "frame")
+	require.Equal(t, failure.KindNotFound, failure.KindOf(err))
+
+	_, err = s.Stat(context.Background(), "frames", "frame")
+	require.Equal(t, failure.KindNotFound, failure.KindOf(err))
+}
+
+func testMemoryInjectsFailures(t *testing.T, s *MemoryStorage) {
+	injected := failure.Transient(errors.New("slow down"))
+	s.StoreHook = func(ctx context.Context, bucket string, objectName string) error { return injected }
+	s.RetrieveHook = func(ctx context.Context, bucket string, objectName string) error { return injected }
+
+	require.Equal(t, injected, s.Store(context.Background(), "blobs", "blob", []byte("blob"), ObjectInfo{}))
+	require.Empty(t, s.Objects("blobs"))
+
+	s.Put("frames", "frame", MemoryObject{Bytes: []byte("frame")})
+	_, err := s.Retrieve(context.Background(), "frames", "frame")
+	require.Equal(t, injected, err)
+}
+
+func testMemoryUpdatesInfo(t *testing.T, s *MemoryStorage) {
+	ctx := context.Background()
+	require.Equal(t, ErrNoSuchObject, s.UpdateInfo(ctx, "blobs", "blob", ObjectInfo{}))
+
+	require.NoError(t, s.Store(ctx, "blobs", "blob", []byte("blob"), ObjectInfo{ContentType: "text/plain"}))
+	require.NoError(t, s.UpdateInfo(ctx, "blobs", "blob", ObjectInfo{
+		ContentEncoding: "gzip",
+		Metadata:        map[string]string{"Checksum": "c1"},
+	}))
+
+	object, ok := s.Object("blobs", "blob")
+	require.True(t, ok)
+	require.Equal(t, []byte("blob"), object.Bytes)
+	require.Equal(t, ObjectInfo{ContentEncoding: "gzip", Metadata: map[string]string{"Checksum": "c1"}}, object.ObjectInfo)
+}
@@ -3,13 +3,42 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io"
+	"net/http"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 
 	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/validation"
+	"github.com/weak-head/data-pipe/pkg/failure"
 )
 
+const (
+	// streamPartSize is the size of the parts of the multipart upload,
+	// that is used to store the object stream of unknown size.
+	streamPartSize = 16 << 20
+)
+
+// ObjectInfo describes the stored object.
+type ObjectInfo struct {
+	ContentType string
+
+	// ContentEncoding is the encoding of the object content, e.g. "gzip".
+	ContentEncoding string
+
+	// Metadata is the user-defined metadata of the object.
+	Metadata map[string]string
+}
+
+// ObjectStat describes the stored object and its size.
+type ObjectStat struct {
+	ObjectInfo
+
+	SizeBytes int64
+}
+
 // StorageConfig
 type StorageConfig struct {
 	Endpoint  string
@@ -17,10 +46,22 @@ type StorageConfig struct {
 	AccessKey string
 	SecretKey string
 
+	// Files with the access and secret keys,
+	// that take precedence over the keys.
+	AccessKeyFile string
+	SecretKeyFile string
+
 	Region                 string
 	CreateBucketIfNotExist bool
 }
 
+// Validate returns the aggregated validation failures of the storage configuration.
+func (c StorageConfig) Validate() error {
+	var errs validation.Errors
+	errs.Add("endpoint", validation.Addr(c.Endpoint))
+	return errs.Err()
+}
+
 // minioStorage
 type minioStorage struct {
 	config StorageConfig
@@ -60,7 +101,7 @@ func (m *minioStorage) Store(
 	bucket string,
 	objectName string,
 	objectBytes []byte,
-	contentType string,
+	info ObjectInfo,
 ) error {
 	log := m.log.WithFields(logger.Fields{
 		logger.FieldFunction: "minioStorage.Store",
@@ -71,18 +112,25 @@ func (m *minioStorage) Store(
 	if m.config.CreateBucketIfNotExist {
 		if err := m.createBucket(ctx, bucket); err != nil {
 			log.Error(err, "Failed to create a new bucket.")
-			return err
+			return classify(ctx, err)
 		}
 		log.Info("Created a new bucket.")
 	}
 
+	if err := m.removeInfo(ctx, bucket, objectName); err != nil {
+		log.Error(err, "Failed to remove the object info.")
+		return err
+	}
+
 	r := bytes.NewReader(objectBytes)
 	_, err := m.client.PutObject(ctx, bucket, objectName, r, r.Size(), minio.PutObjectOptions{
-		ContentType: contentType,
+		ContentType:     info.ContentType,
+		ContentEncoding: info.ContentEncoding,
+		UserMetadata:    info.Metadata,
 	})
 	if err != nil {
 		log.Error(err, "Failed to store the object.")
-		return err
+		return classify(ctx, err)
 	}
 
 	log.Info("Uploaded a new object to the storage.")
@@ -104,19 +152,231 @@ func (m *minioStorage) Retrieve(
 	stream, err := m.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
 	if err != nil {
 		log.Error(err, "Failed to retrieve the object stream from the storage.")
-		return nil, err
+		return nil, classifyRetrieve(ctx, err)
 	}
 
 	buf := new(bytes.Buffer)
 	if _, err := buf.ReadFrom(stream); err != nil {
 		log.Error(err, "Failed to read the object from the storage.")
-		return nil, err
+		return nil, classifyRetrieve(ctx, err)
 	}
 
 	log.Info("Retrieved the object from the storage.")
 	return buf.Bytes(), nil
 }
 
+// StoreStream stores the object that is read from the reader until EOF.
+// The object is uploaded with the multipart upload, so the object size
+// doesn't need to be known in advance, and the object is not buffered in memory.
+func (m *minioStorage) StoreStream(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	r io.Reader,
+	info ObjectInfo,
+) error {
+	log := m.log.WithFields(logger.Fields{
+		logger.FieldFunction: "minioStorage.StoreStream",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	if m.config.CreateBucketIfNotExist {
+		if err := m.createBucket(ctx, bucket); err != nil {
+			log.Error(err, "Failed to create a new bucket.")
+			return classify(ctx, err)
+		}
+		log.Info("Created a new bucket.")
+	}
+
+	if err := m.removeInfo(ctx, bucket, objectName); err != nil {
+		log.Error(err, "Failed to remove the object info.")
+		return err
+	}
+
+	_, err := m.client.PutObject(ctx, bucket, objectName, r, -1, minio.PutObjectOptions{
+		ContentType:     info.ContentType,
+		ContentEncoding: info.ContentEncoding,
+		UserMetadata:    info.Metadata,
+		PartSize:        streamPartSize,
+	})
+	if err != nil {
+		log.Error(err, "Failed to store the object stream.")
+		return classify(ctx, err)
+	}
+
+	log.Info("Uploaded a new object stream to the storage.")
+	return nil
+}
+
+// RetrieveStream returns the reader of the object.
+// The read failures are classified the same way as the Retrieve failures.
+// The caller should close the returned reader.
+func (m *minioStorage) RetrieveStream(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) (io.ReadCloser, error) {
+	log := m.log.WithFields(logger.Fields{
+		logger.FieldFunction: "minioStorage.RetrieveStream",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	object, err := m.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		log.Error(err, "Failed to retrieve the object stream from the storage.")
+		return nil, classifyRetrieve(ctx, err)
+	}
+
+	// The object is requested lazily,
+	// so the missing object is detected before the stream is returned.
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		log.Error(err, "Failed to retrieve the object stream from the storage.")
+		return nil, classifyRetrieve(ctx, err)
+	}
+
+	log.Info("Retrieved the object stream from the storage.")
+	return &objectReader{ctx: ctx, object: object}, nil
+}
+
+// Stat returns the description of the stored object.
+// The object with the info sidecar is described by the sidecar (see UpdateInfo).
+// The missing object or bucket is a permanent failure.
+func (m *minioStorage) Stat(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+) (ObjectStat, error) {
+	log := m.log.WithFields(logger.Fields{
+		logger.FieldFunction: "minioStorage.Stat",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	info, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		log.Debug("Failed to stat the object.")
+		return ObjectStat{}, classifyRetrieve(ctx, err)
+	}
+
+	stat := ObjectStat{
+		ObjectInfo: ObjectInfo{
+			ContentType:     info.ContentType,
+			ContentEncoding: info.Metadata.Get("Content-Encoding"),
+			Metadata:        info.UserMetadata,
+		},
+		SizeBytes: info.Size,
+	}
+
+	sidecar, ok, err := m.readInfo(ctx, bucket, objectName)
+	if err != nil {
+		log.Error(err, "Failed to read the object info.")
+		return ObjectStat{}, err
+	}
+	if ok {
+		stat.ObjectInfo = sidecar
+	}
+
+	return stat, nil
+}
+
+// UpdateInfo replaces the content type, encoding and metadata of the stored object.
+// The info is stored in the info sidecar object next to the object, e.g. ".frame.bin.info",
+// so the object itself is not copied, and Stat describes the object with the sidecar.
+func (m *minioStorage) UpdateInfo(
+	ctx context.Context,
+	bucket string,
+	objectName string,
+	info ObjectInfo,
+) error {
+	log := m.log.WithFields(logger.Fields{
+		logger.FieldFunction: "minioStorage.UpdateInfo",
+		"bucket":             bucket,
+		"objectName":         objectName,
+	})
+
+	if _, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{}); err != nil {
+		log.Error(err, "Failed to stat the object.")
+		return classifyRetrieve(ctx, err)
+	}
+
+	b, err := encodeInfo(info)
+	if err != nil {
+		log.Error(err, "Failed to encode the object info.")
+		return err
+	}
+
+	r := bytes.NewReader(b)
+	_, err = m.client.PutObject(ctx, bucket, infoName(objectName), r, r.Size(), minio.PutObjectOptions{
+		ContentType: contentTypeInfo,
+	})
+	if err != nil {
+		log.Error(err, "Failed to store the object info.")
+		return classify(ctx, err)
+	}
+
+	log.Info("Updated the object info.")
+	return nil
+}
+
+// readInfo reads the info sidecar of the object.
+// It returns false if the object has no info sidecar.
+func (m *minioStorage) readInfo(ctx context.Context, bucket string, objectName string) (ObjectInfo, bool, error) {
+	object, err := m.client.GetObject(ctx, bucket, infoName(objectName), minio.GetObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, false, classifyRetrieve(ctx, err)
+	}
+	defer object.Close()
+
+	b, err := io.ReadAll(object)
+	if err != nil {
+		err = classifyRetrieve(ctx, err)
+		if failure.KindOf(err) == failure.KindNotFound {
+			return ObjectInfo{}, false, nil
+		}
+		return ObjectInfo{}, false, err
+	}
+
+	info, err := decodeInfo(b)
+	if err != nil {
+		return ObjectInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+// removeInfo removes the info sidecar of the replaced object,
+// so the object is never described by the info of the replaced object.
+func (m *minioStorage) removeInfo(ctx context.Context, bucket string, objectName string) error {
+	err := m.client.RemoveObject(ctx, bucket, infoName(objectName), minio.RemoveObjectOptions{})
+	if err != nil {
+		err = classifyRetrieve(ctx, err)
+		if failure.KindOf(err) != failure.KindNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectReader classifies the failures to read the object.
+type objectReader struct {
+	ctx    context.Context
+	object *minio.Object
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	n, err := r.object.Read(p)
+	if err != nil && err != io.EOF {
+		err = classifyRetrieve(r.ctx, err)
+	}
+	return n, err
+}
+
+func (r *objectReader) Close() error {
+	return r.object.Close()
+}
+
 // createBucket
 func (m *minioStorage) createBucket(ctx context.Context, bucket string) error {
 	log := m.log.WithFields(logger.Fields{
@@ -141,3 +401,52 @@ func (m *minioStorage) createBucket(ctx context.Context, bucket string) error {
 	log.Info("A new bucket has been created.")
 	return nil
 }
+
+// transientCodes are the error codes of the storage responses without the status,
+// that are transient.
+var transientCodes = map[string]bool{
+	"InternalError":      true,
+	"RequestTimeout":     true,
+	"ServiceUnavailable": true,
+	"SlowDown":           true,
+}
+
+// classify classifies the storage failure.
+// The failures that could succeed on retry are transient: the server errors,
+// the request timeouts, the throttled requests and the network failures.
+// The other client errors, e.g. the denied access, the invalid bucket name or the invalid
+// metadata value, that is rejected by the client before the request, are rejected.
+// The failures caused by the canceled context are canceled.
+func classify(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return failure.Canceled(err)
+	}
+
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return failure.Transient(err)
+	}
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError,
+		resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == 0 && transientCodes[resp.Code]:
+		return failure.Transient(err)
+	default:
+		return failure.Rejected(err)
+	}
+}
+
+// classifyRetrieve classifies the failure to retrieve an object.
+// The missing object or bucket is a permanent failure.
+func classifyRetrieve(ctx context.Context, err error) error {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		switch {
+		case resp.Code == "NoSuchKey", resp.Code == "NoSuchBucket", resp.StatusCode == http.StatusNotFound:
+			return failure.NotFound(err)
+		}
+	}
+	return classify(ctx, err)
+}
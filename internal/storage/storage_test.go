@@ -1 +1,75 @@
 package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/pkg/failure"
+)
+
+func TestFailureClassification(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for scenario, tc := range map[string]struct {
+		ctx  context.Context
+		err  error
+		kind failure.Kind
+	}{
+		"missing object":      {context.Background(), minio.ErrorResponse{Code: "NoSuchKey"}, failure.KindNotFound},
+		"missing bucket":      {context.Background(), minio.ErrorResponse{Code: "NoSuchBucket"}, failure.KindNotFound},
+		"not found status":    {context.Background(), minio.ErrorResponse{StatusCode: http.StatusNotFound}, failure.KindNotFound},
+		"unavailable storage": {context.Background(), minio.ErrorResponse{Code: "SlowDown"}, failure.KindTransient},
+		"server error":        {context.Background(), minio.ErrorResponse{StatusCode: http.StatusBadGateway}, failure.KindTransient},
+		"request timeout":     {context.Background(), minio.ErrorResponse{StatusCode: http.StatusRequestTimeout}, failure.KindTransient},
+		"throttled request":   {context.Background(), minio.ErrorResponse{StatusCode: http.StatusTooManyRequests}, failure.KindTransient},
+		"access denied":       {context.Background(), minio.ErrorResponse{StatusCode: http.StatusForbidden, Code: "AccessDenied"}, failure.KindRejected},
+		"invalid bucket name": {context.Background(), minio.ErrorResponse{StatusCode: http.StatusBadRequest, Code: "InvalidBucketName"}, failure.KindRejected},
+		"too large entity":    {context.Background(), minio.ErrorResponse{StatusCode: http.StatusBadRequest, Code: "EntityTooLarge"}, failure.KindRejected},
+		"unknown error code":  {context.Background(), minio.ErrorResponse{Code: "InvalidArgument"}, failure.KindRejected},
+		"connection failure":  {context.Background(), errors.New("connection refused"), failure.KindTransient},
+		"canceled context":    {canceled, errors.New("connection closed"), failure.KindCanceled},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			err := classifyRetrieve(tc.ctx, tc.err)
+			require.Equal(t, tc.kind, failure.KindOf(err))
+			require.True(t, errors.Is(err, tc.err))
+		})
+	}
+}
+
+func TestMinioRejectsInvalidMetadata(t *testing.T) {
+	var puts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Has("location"):
+			w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut:
+			puts++
+		}
+	}))
+	defer server.Close()
+
+	log, _ := logger.NewNullLogger()
+	st, err := NewMinioStorage(StorageConfig{Endpoint: strings.TrimPrefix(server.URL, "http://")}, log)
+	require.NoError(t, err)
+
+	err = st.Store(context.Background(), "blobs", "blob", []byte("blob"), ObjectInfo{
+		Metadata: map[string]string{"Attribute-Source": "camera\r\n"},
+	})
+
+	require.Equal(t, failure.KindRejected, failure.KindOf(err))
+	require.True(t, failure.IsPermanent(err))
+	require.Equal(t, "InvalidArgument", minio.ToErrorResponse(errors.Unwrap(err)).Code)
+	require.Zero(t, puts)
+}
@@ -1,10 +1,22 @@
 package stream
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"strconv"
 
 	kafka "github.com/segmentio/kafka-go"
+
+	"github.com/weak-head/data-pipe/internal/validation"
+)
+
+var (
+	// ErrNoBrokers happens when no kafka brokers are provided.
+	ErrNoBrokers = errors.New("no brokers provided")
+
+	// ErrUnknownBalancer happens when the partition balancer is not supported.
+	ErrUnknownBalancer = errors.New("unknown balancer")
 )
 
 // TopicConfig
@@ -17,7 +29,7 @@ type TopicConfig struct {
 
 // ReaderConfig
 type ReaderConfig struct {
-	TopicConfig
+	TopicConfig `mapstructure:",squash"`
 
 	Brokers  []string
 	GroupID  string
@@ -27,14 +39,61 @@ type ReaderConfig struct {
 
 // WriterConfig
 type WriterConfig struct {
-	TopicConfig
+	TopicConfig `mapstructure:",squash"`
 
 	Addr     string
 	Balancer string
 }
 
+// Validate returns the aggregated validation failures of the topic configuration.
+func (c TopicConfig) Validate() error {
+	var errs validation.Errors
+	errs.Add("topic", validation.Required(c.Topic))
+	errs.Add("numpartitions", validation.Positive(c.NumPartitions))
+	errs.Add("replicationfactor", validation.Positive(c.ReplicationFactor))
+	return errs.Err()
+}
+
+// Validate returns the aggregated validation failures of the reader configuration.
+func (c ReaderConfig) Validate() error {
+	var errs validation.Errors
+	errs.Merge("", c.TopicConfig.Validate())
+
+	if len(c.Brokers) == 0 {
+		errs.Add("brokers", ErrNoBrokers)
+	}
+	for i, broker := range c.Brokers {
+		errs.Add(fmt.Sprintf("brokers[%d]", i), validation.Addr(broker))
+	}
+
+	errs.Add("groupid", validation.Required(c.GroupID))
+
+	if c.MaxBytes != 0 && c.MinBytes > c.MaxBytes {
+		errs.Add("minbytes", fmt.Errorf("should not exceed maxbytes (%d)", c.MaxBytes))
+	}
+
+	return errs.Err()
+}
+
+// Validate returns the aggregated validation failures of the writer configuration.
+func (c WriterConfig) Validate() error {
+	var errs validation.Errors
+	errs.Merge("", c.TopicConfig.Validate())
+	errs.Add("addr", validation.Addr(c.Addr))
+
+	if _, err := createBalancer(c.Balancer); err != nil {
+		errs.Add("balancer", err)
+	}
+
+	return errs.Err()
+}
+
 // NewReader
 func NewReader(config ReaderConfig) (*kafka.Reader, error) {
+	if len(config.Brokers) == 0 {
+		return nil, ErrNoBrokers
+	}
+
 	if err := createTopic(config.Brokers[0], config.TopicConfig); err != nil {
 		return nil, err
 	}
@@ -50,6 +109,11 @@ func NewReader(config ReaderConfig) (*kafka.Reader, error) {
 
 // NewWriter
 func NewWriter(config WriterConfig) (*kafka.Writer, error) {
+	balancer, err := createBalancer(config.Balancer)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := createTopic(config.Addr, config.TopicConfig); err != nil {
 		return nil, err
 	}
@@ -57,7 +121,7 @@ func NewWriter(config WriterConfig) (*kafka.Writer, error) {
 	return &kafka.Writer{
 		Addr:     kafka.TCP(config.Addr),
 		Topic:    config.Topic,
-		Balancer: createBalancer(config.Balancer),
+		Balancer: balancer,
 	}, nil
 }
 
@@ -109,30 +173,31 @@ func createTopic(addr string, config TopicConfig) error {
 }
 
 // createBalancer
-func createBalancer(balancer string) kafka.Balancer {
+func createBalancer(balancer string) (kafka.Balancer, error) {
 	switch balancer {
 
 	// Classical round robin
 	case "roundrobin":
-		return &kafka.RoundRobin{}
+		return &kafka.RoundRobin{}, nil
 
-	// Partition that received the least bytes
-	case "leastbytes":
-		return &kafka.LeastBytes{}
+	// Partition that received the least bytes.
+	// This is the default balancer.
+	case "leastbytes", "":
+		return &kafka.LeastBytes{}, nil
 
 	// FNV-1a
 	case "hash":
-		return &kafka.Hash{}
+		return &kafka.Hash{}, nil
 
 	// CRC32 hash
 	case "crc32":
-		return &kafka.CRC32Balancer{}
+		return &kafka.CRC32Balancer{}, nil
 
 	// Murmur2 hash
 	case "murmur2":
-		return &kafka.Murmur2Balancer{}
+		return &kafka.Murmur2Balancer{}, nil
 
 	default:
-		return &kafka.LeastBytes{}
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBalancer, balancer)
 	}
 }
@@ -1 +1,65 @@
 package stream
+
+import (
+	"errors"
+	"testing"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/weak-head/data-pipe/internal/validation"
+)
+
+func TestBalancer(t *testing.T) {
+	for name, expected := range map[string]kafka.Balancer{
+		"":           &kafka.LeastBytes{},
+		"leastbytes": &kafka.LeastBytes{},
+		"roundrobin": &kafka.RoundRobin{},
+		"hash":       &kafka.Hash{},
+		"crc32":      &kafka.CRC32Balancer{},
+		"murmur2":    &kafka.Murmur2Balancer{},
+	} {
+		balancer, err := createBalancer(name)
+		require.NoError(t, err)
+		require.IsType(t, expected, balancer)
+	}
+
+	balancer, err := createBalancer("random")
+	require.Nil(t, balancer)
+	require.True(t, errors.Is(err, ErrUnknownBalancer))
+}
+
+func TestReaderCreation(t *testing.T) {
+	reader, err := NewReader(ReaderConfig{})
+	require.Nil(t, reader)
+	require.Equal(t, ErrNoBrokers, err)
+}
+
+func TestConfigValidation(t *testing.T) {
+	reader := ReaderConfig{
+		TopicConfig: TopicConfig{Topic: "frames", NumPartitions: 0, ReplicationFactor: 1},
+		Brokers:     []string{"kafka:9092", "kafka"},
+		GroupID:     "data-pipe",
+	}
+
+	var errs validation.Errors
+	require.True(t, errors.As(reader.Validate(), &errs))
+	require.Equal(t, []string{"numpartitions", "brokers[1]"}, paths(errs))
+
+	writer := WriterConfig{
+		TopicConfig: TopicConfig{Topic: "blobs", NumPartitions: 1, ReplicationFactor: 1},
+		Addr:        "kafka:9092",
+		Balancer:    "random",
+	}
+
+	require.True(t, errors.As(writer.Validate(), &errs))
+	require.Equal(t, []string{"balancer"}, paths(errs))
+}
+
+func paths(errs validation.Errors) []string {
+	var p []string
+	for _, e := range errs {
+		p = append(p, e.Path)
+	}
+	return p
+}
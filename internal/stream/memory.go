@@ -0,0 +1,311 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+var (
+	// ErrClosed happens when the closed in-memory reader or writer is used.
+	ErrClosed = errors.New("stream is closed")
+)
+
+// MemoryTopic is an in-memory topic with partition and offset semantics,
+// that is used to run the pipelines without kafka,
+// e.g. in the end-to-end tests of the pipelines.
+//
+// The messages are appended to the partitions by the writers,
+// and read by the readers of a single consumer group.
+// A new reader starts from the committed offsets,
+// so the uncommitted messages are redelivered to the new reader,
+// the same way as they are redelivered by kafka on restart.
+type MemoryTopic struct {
+	mu         sync.Mutex
+	name       string
+	partitions [][]kafka.Message
+	committed  []int64
+	balancer   kafka.Balancer
+
+	// produced is closed and replaced each time
+	// new messages are appended to the topic.
+	produced chan struct{}
+}
+
+// NewMemoryTopic creates a new in-memory topic with the given number of partitions.
+// The messages with a key are assigned to the partition by the key hash,
+// the other messages are assigned to the partitions in the round robin.
+func NewMemoryTopic(name string, partitions int) *MemoryTopic {
+	if partitions <= 0 {
+		partitions = 1
+	}
+
+	return &MemoryTopic{
+		name:       name,
+		partitions: make([][]kafka.Message, partitions),
+		committed:  make([]int64, partitions),
+		balancer:   &kafka.Hash{},
+		produced:   make(chan struct{}),
+	}
+}
+
+// Name returns the name of the topic.
+func (t *MemoryTopic) Name() string {
+	return t.name
+}
+
+// Produce appends the messages to the topic partitions,
+// and returns the messages with the assigned topic, partition and offset.
+func (t *MemoryTopic) Produce(msgs ...kafka.Message) []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]int, len(t.partitions))
+	for i := range ids {
+		ids[i] = i
+	}
+
+	produced := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		m.Topic = t.name
+		m.Partition = t.balancer.Balance(m, ids...)
+		m.Offset = int64(len(t.partitions[m.Partition]))
+		if m.Time.IsZero() {
+			m.Time = time.Now()
+		}
+
+		t.partitions[m.Partition] = append(t.partitions[m.Partition], m)
+		produced = append(produced, m)
+	}
+
+	close(t.produced)
+	t.produced = make(chan struct{})
+
+	return produced
+}
+
+// Messages returns all messages of the topic, ordered by partition and offset.
+func (t *MemoryTopic) Messages() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var msgs []kafka.Message
+	for _, partition := range t.partitions {
+		msgs = append(msgs, partition...)
+	}
+	return msgs
+}
+
+// Committed returns the committed offset of each partition.
+// The committed offset is the offset of the next message
+// that should be read from the partition.
+func (t *MemoryTopic) Committed() map[int]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	committed := make(map[int]int64, len(t.committed))
+	for partition, offset := range t.committed {
+		committed[partition] = offset
+	}
+	return committed
+}
+
+// Lag returns the number of the messages that are not committed.
+func (t *MemoryTopic) Lag() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var lag int64
+	for partition, msgs := range t.partitions {
+		lag += int64(len(msgs)) - t.committed[partition]
+	}
+	return lag
+}
+
+// NewReader creates a new reader, that starts from the committed offsets.
+func (t *MemoryTopic) NewReader() *MemoryReader {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &MemoryReader{
+		topic: t,
+		next:  append([]int64(nil), t.committed...),
+	}
+}
+
+// NewWriter creates a new writer, that appends the messages to the topic.
+func (t *MemoryTopic) NewWriter() *MemoryWriter {
+	return &MemoryWriter{topic: t}
+}
+
+// commit commits the offsets of the messages,
+// the committed offset never moves backwards.
+func (t *MemoryTopic) commit(msgs ...kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range msgs {
+		if m.Partition < 0 || m.Partition >= len(t.committed) {
+			continue
+		}
+		if m.Offset+1 > t.committed[m.Partition] {
+			t.committed[m.Partition] = m.Offset + 1
+		}
+	}
+}
+
+// MemoryReader reads the messages from the in-memory topic.
+//
+// The hooks are used to inject the failures,
+// and should be set before the reader is used.
+type MemoryReader struct {
+	// FetchHook is called before the message is fetched,
+	// the fetch fails with the returned error.
+	FetchHook func(ctx context.Context) error
+
+	// CommitHook is called before the messages are committed,
+	// the commit fails with the returned error.
+	CommitHook func(ctx context.Context, msgs ...kafka.Message) error
+
+	topic *MemoryTopic
+
+	mu     sync.Mutex
+	next   []int64
+	cursor int
+	closed bool
+}
+
+// FetchMessage returns the next message of the topic.
+// The partitions are read in the round robin.
+// It blocks until a new message is available or the context is done.
+func (r *MemoryReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if r.FetchHook != nil {
+		if err := r.FetchHook(ctx); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+
+	for {
+		m, produced, err := r.poll()
+		if err != nil {
+			return kafka.Message{}, err
+		}
+		if produced == nil {
+			return m, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-produced:
+		}
+	}
+}
+
+// poll returns the next available message,
+// or the channel that is closed once new messages are produced.
+func (r *MemoryReader) poll() (kafka.Message, <-chan struct{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return kafka.Message{}, nil, ErrClosed
+	}
+
+	r.topic.mu.Lock()
+	defer r.topic.mu.Unlock()
+
+	partitions := len(r.topic.partitions)
+	for i := 0; i < partitions; i++ {
+		partition := (r.cursor + i) % partitions
+		if r.next[partition] < int64(len(r.topic.partitions[partition])) {
+			m := r.topic.partitions[partition][r.next[partition]]
+			m.HighWaterMark = int64(len(r.topic.partitions[partition]))
+			r.next[partition]++
+			r.cursor = partition + 1
+			return m, nil, nil
+		}
+	}
+
+	return kafka.Message{}, r.topic.produced, nil
+}
+
+// CommitMessages commits the offsets of the messages.
+func (r *MemoryReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if r.CommitHook != nil {
+		if err := r.CommitHook(ctx, msgs...); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	r.topic.commit(msgs...)
+	return nil
+}
+
+// Close closes the reader.
+func (r *MemoryReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	return nil
+}
+
+// MemoryWriter writes the messages to the in-memory topic.
+//
+// The hook is used to inject the failures,
+// and should be set before the writer is used.
+type MemoryWriter struct {
+	// WriteHook is called before the messages are written,
+	// the write fails with the returned error.
+	WriteHook func(ctx context.Context, msgs ...kafka.Message) error
+
+	topic *MemoryTopic
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// WriteMessages appends the messages to the topic.
+// The messages are written atomically, either all or none of them.
+func (w *MemoryWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.WriteHook != nil {
+		if err := w.WriteHook(ctx, msgs...); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.topic.Produce(msgs...)
+	return nil
+}
+
+// Close closes the writer.
+func (w *MemoryWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	return nil
+}
@@ -0,0 +1,140 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTopic(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, topic *MemoryTopic){
+		"assigns partitions and offsets":      testMemoryAssignsOffsets,
+		"redelivers uncommitted messages":     testMemoryRedelivers,
+		"never moves committed offset back":   testMemoryCommitsForward,
+		"blocks fetch until message produced": testMemoryBlocksFetch,
+		"injects the failures with hooks":     testMemoryInjectsFailures,
+		"fails to use the closed reader":      testMemoryClosed,
+		"sets the high water mark":            testMemorySetsHighWaterMark,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			fn(t, NewMemoryTopic("frames", 2))
+		})
+	}
+}
+
+func testMemoryAssignsOffsets(t *testing.T, topic *MemoryTopic) {
+	produced := topic.Produce(
+		kafka.Message{Key: []byte("a")},
+		kafka.Message{Key: []byte("a")},
+		kafka.Message{Key: []byte("a")},
+	)
+
+	for i, m := range produced {
+		require.Equal(t, "frames", m.Topic)
+		require.Equal(t, produced[0].Partition, m.Partition)
+		require.Equal(t, int64(i), m.Offset)
+	}
+	require.Equal(t, int64(3), topic.Lag())
+}
+
+func testMemoryRedelivers(t *testing.T, topic *MemoryTopic) {
+	ctx := context.Background()
+	topic.Produce(kafka.Message{Value: []byte("0")}, kafka.Message{Value: []byte("1")})
+
+	reader := topic.NewReader()
+	first, err := reader.FetchMessage(ctx)
+	require.NoError(t, err)
+	second, err := reader.FetchMessage(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, first.Partition, second.Partition)
+
+	require.NoError(t, reader.CommitMessages(ctx, first))
+	require.NoError(t, reader.Close())
+
+	redelivered, err := topic.NewReader().FetchMessage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, second, redelivered)
+	require.Equal(t, int64(1), topic.Lag())
+}
+
+func testMemoryCommitsForward(t *testing.T, topic *MemoryTopic) {
+	produced := topic.Produce(kafka.Message{Key: []byte("a")}, kafka.Message{Key: []byte("a")})
+	partition := produced[0].Partition
+
+	reader := topic.NewReader()
+	require.NoError(t, reader.CommitMessages(context.Background(), produced[1]))
+	require.NoError(t, reader.CommitMessages(context.Background(), produced[0]))
+
+	require.Equal(t, int64(2), topic.Committed()[partition])
+}
+
+func testMemoryBlocksFetch(t *testing.T, topic *MemoryTopic) {
+	reader := topic.NewReader()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := reader.FetchMessage(ctx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	fetched := make(chan kafka.Message)
+	go func() {
+		m, _ := reader.FetchMessage(context.Background())
+		fetched <- m
+	}()
+
+	require.NoError(t, topic.NewWriter().WriteMessages(context.Background(), kafka.Message{Value: []byte("frame")}))
+	require.Equal(t, []byte("frame"), (<-fetched).Value)
+}
+
+func testMemoryInjectsFailures(t *testing.T, topic *MemoryTopic) {
+	injected := errors.New("broker not available")
+	ctx := context.Background()
+
+	writer := topic.NewWriter()
+	writer.WriteHook = func(ctx context.Context, msgs ...kafka.Message) error { return injected }
+	require.Equal(t, injected, writer.WriteMessages(ctx, kafka.Message{}))
+	require.Empty(t, topic.Messages())
+
+	produced := topic.Produce(kafka.Message{})
+
+	reader := topic.NewReader()
+	reader.FetchHook = func(ctx context.Context) error { return injected }
+	reader.CommitHook = func(ctx context.Context, msgs ...kafka.Message) error { return injected }
+
+	_, err := reader.FetchMessage(ctx)
+	require.Equal(t, injected, err)
+	require.Equal(t, injected, reader.CommitMessages(ctx, produced...))
+	require.Equal(t, int64(1), topic.Lag())
+}
+
+func testMemoryClosed(t *testing.T, topic *MemoryTopic) {
+	reader := topic.NewReader()
+	require.NoError(t, reader.Close())
+
+	_, err := reader.FetchMessage(context.Background())
+	require.Equal(t, ErrClosed, err)
+
+	writer := topic.NewWriter()
+	require.NoError(t, writer.Close())
+	require.Equal(t, ErrClosed, writer.WriteMessages(context.Background(), kafka.Message{}))
+}
+
+func testMemorySetsHighWaterMark(t *testing.T, topic *MemoryTopic) {
+	ctx := context.Background()
+	topic.Produce(kafka.Message{Key: []byte("a")}, kafka.Message{Key: []byte("a")})
+
+	reader := topic.NewReader()
+	first, err := reader.FetchMessage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), first.HighWaterMark)
+
+	topic.Produce(kafka.Message{Key: []byte("a")})
+
+	second, err := reader.FetchMessage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), second.HighWaterMark)
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/validation"
+)
+
+var (
+	// ErrInvalidSampleRatio happens when the sample ratio is not within [0, 1].
+	ErrInvalidSampleRatio = errors.New("should be within [0, 1]")
+
+	// propagator propagates the trace context and the baggage
+	// in the kafka message headers.
+	propagator = propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+)
+
+// Config defines how the spans are exported.
+type Config struct {
+	// Endpoint of the OTLP gRPC collector, e.g. "otel-collector:4317".
+	// The tracing is disabled if not set.
+	Endpoint string
+
+	// Insecure disables the TLS of the collector connection.
+	Insecure bool
+
+	// SampleRatio is the ratio of the sampled traces,
+	// that are started by the service. The traces that are started upstream
+	// are sampled according to the sampling decision of the parent.
+	SampleRatio float64
+}
+
+// Validate returns the aggregated validation failures of the tracing configuration.
+func (c Config) Validate() error {
+	var errs validation.Errors
+	errs.Add("endpoint", validation.Addr(c.Endpoint))
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs.Add("sampleratio", ErrInvalidSampleRatio)
+	}
+	return errs.Err()
+}
+
+// NewProvider creates a new tracer provider, that exports
+// the spans of the service to the OTLP collector in batches.
+// The provider should be shut down to export the remaining spans.
+func NewProvider(ctx context.Context, config Config, service string, log logger.Log) (*sdktrace.TracerProvider, error) {
+	l := log.WithFields(logger.Fields{
+		logger.FieldPackage:  "tracing",
+		logger.FieldFunction: "NewProvider",
+	})
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		l.Error(err, "Failed to create the OTLP exporter.")
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(service),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+
+	l.Info("Created a new tracer provider.")
+	return provider, nil
+}
+
+// Extract returns the context with the remote span context
+// and the baggage, that are extracted from the message headers.
+func Extract(ctx context.Context, headers []kafka.Header) context.Context {
+	return propagator.Extract(ctx, &headerCarrier{headers: &headers})
+}
+
+// Inject returns the message headers with the span context
+// and the baggage of the context. The existing trace headers are replaced,
+// the given headers are not modified.
+func Inject(ctx context.Context, headers []kafka.Header) []kafka.Header {
+	headers = append([]kafka.Header(nil), headers...)
+	propagator.Inject(ctx, &headerCarrier{headers: &headers})
+	return headers
+}
+
+// End records the failure of the span, if any, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// headerCarrier adapts the kafka message headers to propagation.TextMapCarrier.
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c *headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c *headerCarrier) Set(key string, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c *headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
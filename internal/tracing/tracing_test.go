@@ -0,0 +1,181 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collector "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+
+	"github.com/weak-head/data-pipe/internal/logger"
+	"github.com/weak-head/data-pipe/internal/validation"
+)
+
+func TestTracing(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"propagates the span context":        testPropagatesSpanContext,
+		"replaces the trace headers":         testReplacesTraceHeaders,
+		"extracts nothing without headers":   testExtractsNothing,
+		"records the failed spans":           testRecordsFailedSpans,
+		"validates the configuration":        testValidatesConfig,
+		"exports the spans to the collector": testExportsSpans,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func nullLogger() logger.Log {
+	log, _ := logger.NewNullLogger()
+	return log
+}
+
+func testPropagatesSpanContext(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "span")
+	defer span.End()
+
+	member, err := baggage.NewMember("tenant", "camera")
+	require.NoError(t, err)
+	bag, err := baggage.New(member)
+	require.NoError(t, err)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	input := []kafka.Header{{Key: "frame-id", Value: []byte("frame")}}
+	headers := Inject(ctx, input)
+
+	require.Len(t, input, 1)
+	require.Equal(t, input[0], headers[0])
+
+	extracted := Extract(context.Background(), headers)
+	sc := trace.SpanContextFromContext(extracted)
+	require.True(t, sc.IsRemote())
+	require.Equal(t, span.SpanContext().TraceID(), sc.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), sc.SpanID())
+	require.Equal(t, "camera", baggage.FromContext(extracted).Member("tenant").Value())
+}
+
+func testReplacesTraceHeaders(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+
+	first, span := provider.Tracer("test").Start(context.Background(), "first")
+	span.End()
+	second, span := provider.Tracer("test").Start(context.Background(), "second")
+	span.End()
+
+	headers := Inject(second, Inject(first, nil))
+
+	var traceparents int
+	for _, h := range headers {
+		if h.Key == "traceparent" {
+			traceparents++
+		}
+	}
+	require.Equal(t, 1, traceparents)
+
+	sc := trace.SpanContextFromContext(Extract(context.Background(), headers))
+	require.Equal(t, span.SpanContext().TraceID(), sc.TraceID())
+}
+
+func testExtractsNothing(t *testing.T) {
+	ctx := Extract(context.Background(), []kafka.Header{{Key: "frame-id", Value: []byte("frame")}})
+	require.False(t, trace.SpanContextFromContext(ctx).IsValid())
+}
+
+func testRecordsFailedSpans(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+
+	_, span := provider.Tracer("test").Start(context.Background(), "succeeded")
+	End(span, nil)
+	_, span = provider.Tracer("test").Start(context.Background(), "failed")
+	End(span, errors.New("failure"))
+
+	spans := rec.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, codes.Unset, spans[0].Status().Code)
+	require.Equal(t, codes.Error, spans[1].Status().Code)
+	require.Equal(t, "failure", spans[1].Status().Description)
+	require.Len(t, spans[1].Events(), 1)
+}
+
+func testValidatesConfig(t *testing.T) {
+	require.NoError(t, Config{Endpoint: "collector:4317", SampleRatio: 0.5}.Validate())
+
+	var errs validation.Errors
+	require.True(t, errors.As(Config{Endpoint: "collector", SampleRatio: 1.5}.Validate(), &errs))
+	require.Len(t, errs, 2)
+	require.Equal(t, "endpoint", errs[0].Path)
+	require.True(t, errors.Is(errs[0].Err, validation.ErrInvalidAddr))
+	require.Equal(t, "sampleratio", errs[1].Path)
+	require.True(t, errors.Is(errs[1].Err, ErrInvalidSampleRatio))
+}
+
+func testExportsSpans(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	col := &traceCollector{}
+	srv := grpc.NewServer()
+	collector.RegisterTraceServiceServer(srv, col)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx := context.Background()
+	provider, err := NewProvider(ctx, Config{
+		Endpoint:    lis.Addr().String(),
+		Insecure:    true,
+		SampleRatio: 1,
+	}, "data-pipe", nullLogger())
+	require.NoError(t, err)
+
+	_, span := provider.Tracer("test").Start(ctx, "pipeline.handle")
+	span.End()
+
+	require.NoError(t, provider.Shutdown(ctx))
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	var names []string
+	var service string
+	for _, rs := range col.spans {
+		for _, attr := range rs.GetResource().GetAttributes() {
+			if attr.GetKey() == "service.name" {
+				service = attr.GetValue().GetStringValue()
+			}
+		}
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				names = append(names, s.GetName())
+			}
+		}
+	}
+	require.Equal(t, []string{"pipeline.handle"}, names)
+	require.Equal(t, "data-pipe", service)
+}
+
+// traceCollector is the OTLP collector, that keeps the exported spans.
+type traceCollector struct {
+	collector.UnimplementedTraceServiceServer
+
+	mu    sync.Mutex
+	spans []*tracepb.ResourceSpans
+}
+
+func (c *traceCollector) Export(ctx context.Context, req *collector.ExportTraceServiceRequest) (*collector.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spans = append(c.spans, req.GetResourceSpans()...)
+	return &collector.ExportTraceServiceResponse{}, nil
+}
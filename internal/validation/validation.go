@@ -0,0 +1,126 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrRequired happens when a required field is not set.
+	ErrRequired = errors.New("value is required")
+
+	// ErrNotPositive happens when a field should be positive, but it is not.
+	ErrNotPositive = errors.New("value should be positive")
+
+	// ErrInvalidAddr happens when a field is not a valid host:port address.
+	ErrInvalidAddr = errors.New("invalid host:port address")
+)
+
+// FieldError is a validation failure of a single configuration field.
+type FieldError struct {
+	// Path to the field, e.g. "reader.brokers".
+	Path string
+	Err  error
+}
+
+// Error returns the field path and the validation failure.
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+// Unwrap returns the validation failure.
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Errors aggregates validation failures of multiple configuration fields,
+// so all of them could be reported together.
+type Errors []FieldError
+
+// Error returns all validation failures.
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Add adds a validation failure of the field with the given path.
+// Nil errors are ignored.
+func (e *Errors) Add(path string, err error) {
+	if err == nil {
+		return
+	}
+	*e = append(*e, FieldError{Path: path, Err: err})
+}
+
+// Merge adds the validation failures of the nested configuration,
+// prefixing the field paths with the given path.
+// Nil errors are ignored.
+func (e *Errors) Merge(path string, err error) {
+	var nested Errors
+	if !errors.As(err, &nested) {
+		e.Add(path, err)
+		return
+	}
+
+	for _, fe := range nested {
+		*e = append(*e, FieldError{Path: join(path, fe.Path), Err: fe.Err})
+	}
+}
+
+// Err returns nil if there are no validation failures,
+// otherwise it returns the aggregated failures.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Required returns an error if the value is empty.
+func Required(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return ErrRequired
+	}
+	return nil
+}
+
+// Positive returns an error if the value is not positive.
+func Positive(value int) error {
+	if value <= 0 {
+		return ErrNotPositive
+	}
+	return nil
+}
+
+// Addr returns an error if the value is not a valid host:port address.
+// The host could be omitted, e.g. ":8080".
+func Addr(value string) error {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidAddr, value)
+	}
+
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return fmt.Errorf("%w: %q", ErrInvalidAddr, value)
+	}
+
+	return nil
+}
+
+// join joins the parent and the nested field paths.
+func join(parent, nested string) string {
+	switch {
+	case parent == "":
+		return nested
+	case nested == "":
+		return parent
+	default:
+		return parent + "." + nested
+	}
+}
@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"no failures result in nil error":        testNoFailures,
+		"failures are aggregated with the paths": testAggregatesFailures,
+		"nested failures are prefixed":           testMergesNestedFailures,
+		"plain errors are merged as is":          testMergesPlainErrors,
+		"validates addresses":                    testValidatesAddr,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func testNoFailures(t *testing.T) {
+	var errs Errors
+	errs.Add("topic", nil)
+	errs.Merge("reader", nil)
+
+	require.NoError(t, errs.Err())
+}
+
+func testAggregatesFailures(t *testing.T) {
+	var errs Errors
+	errs.Add("topic", Required(""))
+	errs.Add("numpartitions", Positive(0))
+
+	err := errs.Err()
+	require.Error(t, err)
+	require.True(t, errors.Is(err.(Errors)[0], ErrRequired))
+	require.Equal(
+		t,
+		"invalid configuration: topic: value is required; numpartitions: value should be positive",
+		err.Error())
+}
+
+func testMergesNestedFailures(t *testing.T) {
+	var nested Errors
+	nested.Add("topic", ErrRequired)
+	nested.Add("brokers[0]", ErrInvalidAddr)
+
+	var errs Errors
+	errs.Merge("reader", nested.Err())
+
+	require.Equal(t, Errors{
+		{Path: "reader.topic", Err: ErrRequired},
+		{Path: "reader.brokers[0]", Err: ErrInvalidAddr},
+	}, errs)
+}
+
+func testMergesPlainErrors(t *testing.T) {
+	failure := errors.New("failure")
+
+	var errs Errors
+	errs.Merge("reader", failure)
+
+	require.Equal(t, Errors{{Path: "reader", Err: failure}}, errs)
+}
+
+func testValidatesAddr(t *testing.T) {
+	for _, addr := range []string{"localhost:9092", ":8080", "10.0.0.1:65535", "[::1]:80"} {
+		require.NoError(t, Addr(addr), addr)
+	}
+
+	for _, addr := range []string{"", "localhost", "localhost:", "localhost:port", "localhost:70000", ":0"} {
+		require.True(t, errors.Is(Addr(addr), ErrInvalidAddr), addr)
+	}
+}
@@ -0,0 +1,72 @@
+package convert
+
+import (
+	"context"
+	"sync"
+)
+
+type attributesKey struct{}
+
+// Attributes are the attributes of the converted blob,
+// that are shared with the converter via the context.
+type Attributes struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// WithAttributes returns the context of the conversion, that has
+// a copy of the data frame attributes, and the attributes themselves.
+func WithAttributes(ctx context.Context, values map[string]string) (context.Context, *Attributes) {
+	attrs := &Attributes{values: copyValues(values)}
+	if attrs.values == nil {
+		attrs.values = make(map[string]string)
+	}
+	return context.WithValue(ctx, attributesKey{}, attrs), attrs
+}
+
+// copyValues returns a copy of the attributes, or nil if there are none.
+func copyValues(values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
+
+// Values returns a copy of the attributes, or nil if there are none.
+func (a *Attributes) Values() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return copyValues(a.values)
+}
+
+// SetAttribute sets the attribute of the converted blob.
+// The converter uses SetAttribute to add the attributes to the data frame,
+// that is being converted. SetAttribute does nothing outside of the conversion.
+func SetAttribute(ctx context.Context, key string, value string) {
+	attrs, ok := ctx.Value(attributesKey{}).(*Attributes)
+	if !ok {
+		return
+	}
+
+	attrs.mu.Lock()
+	defer attrs.mu.Unlock()
+	attrs.values[key] = value
+}
+
+// Attribute returns the attribute of the data frame, that is being converted.
+func Attribute(ctx context.Context, key string) (string, bool) {
+	attrs, ok := ctx.Value(attributesKey{}).(*Attributes)
+	if !ok {
+		return "", false
+	}
+
+	attrs.mu.Lock()
+	defer attrs.mu.Unlock()
+	value, ok := attrs.values[key]
+	return value, ok
+}
@@ -0,0 +1,43 @@
+package convert
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributes(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"shares the attributes with converter":     testSharesAttributes,
+		"ignores attributes outside of conversion": testIgnoresAttributesOutside,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+func testSharesAttributes(t *testing.T) {
+	frame := map[string]string{"tenant-id": "t1"}
+	ctx, attrs := WithAttributes(context.Background(), frame)
+
+	tenant, ok := Attribute(ctx, "tenant-id")
+	require.True(t, ok)
+	require.Equal(t, "t1", tenant)
+
+	SetAttribute(ctx, "source", "camera")
+	require.Equal(t, map[string]string{"tenant-id": "t1", "source": "camera"}, attrs.Values())
+
+	// The attributes of the data frame are not changed.
+	require.Equal(t, map[string]string{"tenant-id": "t1"}, frame)
+}
+
+func testIgnoresAttributesOutside(t *testing.T) {
+	ctx := context.Background()
+	SetAttribute(ctx, "source", "camera")
+
+	_, ok := Attribute(ctx, "source")
+	require.False(t, ok)
+
+	_, attrs := WithAttributes(ctx, nil)
+	require.Nil(t, attrs.Values())
+}
@@ -0,0 +1,77 @@
+// Package convert defines the converters of the data frames,
+// and the registry of the converter factories.
+//
+// The converters that are not part of the service implement Converter,
+// and register their factory with Register from the init function of their package,
+// that is imported by the service for the side effects.
+// The conversion failures are classified with the package failure.
+package convert
+
+import (
+	"context"
+	"io"
+)
+
+// Logger is the log, that is passed to the converter factory.
+type Logger interface {
+	Debug(args ...interface{})
+	Debugf(format string, args ...interface{})
+
+	Info(args ...interface{})
+	Infof(format string, args ...interface{})
+
+	Warn(args ...interface{})
+	Warnf(format string, args ...interface{})
+
+	Error(err error, args ...interface{})
+	Errorf(err error, format string, args ...interface{})
+}
+
+// Converter is the interface that wraps the basic Convert method.
+//
+// Convert process the data frame and returns the converted blob.
+// Convert must return a non-nil error if convertion of the data frame has failed.
+// The errors that are not classified by the converter with the package failure
+// are treated as the rejected input (see failure.Rejected).
+type Converter interface {
+	Convert(ctx context.Context, from []byte) (to []byte, err error)
+}
+
+// StreamConverter is the interface that wraps the ConvertStream method.
+//
+// ConvertStream reads the data frame from the reader and writes the converted blob
+// to the writer, so the data frame is not buffered in memory as a whole.
+// The converter that implements StreamConverter is used in the streaming mode,
+// if the storage supports the streams.
+// The errors are handled the same way as the Convert errors.
+type StreamConverter interface {
+	ConvertStream(ctx context.Context, from io.Reader, to io.Writer) error
+}
+
+// Content describes the converted blob, so the consumers know how to decode it.
+type Content struct {
+	// Type is the MIME type of the converted blob.
+	Type string
+
+	// Encoding of the converted blob, e.g. "gzip",
+	// empty if the content is not encoded.
+	Encoding string
+}
+
+// ContentConverter is the interface that wraps the Content method.
+//
+// Content returns the content type and encoding of the converted blobs.
+// The empty content type is detected from the converted blob,
+// if the content type detection is enabled and the content is not encoded.
+type ContentConverter interface {
+	Content() Content
+}
+
+// ContentOf returns the content declared by the converter,
+// or the empty content if the converter doesn't declare it.
+func ContentOf(c Converter) Content {
+	if cc, ok := c.(ContentConverter); ok {
+		return cc.Content()
+	}
+	return Content{}
+}
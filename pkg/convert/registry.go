@@ -0,0 +1,74 @@
+package convert
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Options is the converter specific configuration section.
+type Options map[string]interface{}
+
+// Decode decodes the options into the converter specific configuration.
+// It returns an error if the options contain unknown keys.
+func (o Options) Decode(out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(map[string]interface{}(o))
+}
+
+// Factory creates a new converter from the converter specific options.
+// It returns an error if the options are not valid.
+type Factory func(options Options, log Logger) (Converter, error)
+
+// Register makes the converter factory available by the provided kind.
+// If Register is called twice with the same kind or if factory is nil, it panics.
+func Register(kind string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if factory == nil {
+		panic("convert: Register factory is nil")
+	}
+
+	if _, dup := factories[kind]; dup {
+		panic("convert: Register called twice for converter " + kind)
+	}
+
+	factories[kind] = factory
+}
+
+// Lookup returns the factory of the registered converter.
+func Lookup(kind string) (Factory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	factory, ok := factories[kind]
+	return factory, ok
+}
+
+// Kinds returns a sorted list of the kinds of the registered converters.
+func Kinds() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	kinds := make([]string, 0, len(factories))
+	for kind := range factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
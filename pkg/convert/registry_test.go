@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T){
+		"looks up registered converter":    testLooksUpRegisteredConverter,
+		"panics on duplicate registration": testPanicsOnDuplicateRegistration,
+		"decodes the converter options":    testDecodesOptions,
+		"fails on unknown options":         testFailsOnUnknownOptions,
+	} {
+		t.Run(scenario, fn)
+	}
+}
+
+// converterFunc is an adapter to use a function as a converter.
+type converterFunc func(ctx context.Context, from []byte) ([]byte, error)
+
+func (f converterFunc) Convert(ctx context.Context, from []byte) ([]byte, error) {
+	return f(ctx, from)
+}
+
+func newConverterFunc(options Options, log Logger) (Converter, error) {
+	return converterFunc(func(ctx context.Context, from []byte) ([]byte, error) {
+		return []byte("converted"), nil
+	}), nil
+}
+
+func testLooksUpRegisteredConverter(t *testing.T) {
+	Register("test-lookup", newConverterFunc)
+	require.Contains(t, Kinds(), "test-lookup")
+
+	factory, ok := Lookup("test-lookup")
+	require.True(t, ok)
+
+	c, err := factory(nil, nil)
+	require.NoError(t, err)
+	to, err := c.Convert(context.Background(), []byte("frame"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("converted"), to)
+
+	_, ok = Lookup("unknown")
+	require.False(t, ok)
+}
+
+func testPanicsOnDuplicateRegistration(t *testing.T) {
+	Register("test-duplicate", newConverterFunc)
+	require.Panics(t, func() {
+		Register("test-duplicate", newConverterFunc)
+	})
+	require.Panics(t, func() {
+		Register("test-nil-factory", nil)
+	})
+}
+
+func testDecodesOptions(t *testing.T) {
+	var config struct {
+		Level   int
+		Name    string
+		Timeout time.Duration
+	}
+	err := Options{"level": "5", "name": "step", "timeout": "1s"}.Decode(&config)
+	require.NoError(t, err)
+
+	require.Equal(t, 5, config.Level)
+	require.Equal(t, "step", config.Name)
+	require.Equal(t, time.Second, config.Timeout)
+}
+
+func testFailsOnUnknownOptions(t *testing.T) {
+	var config struct{ Level int }
+	require.Error(t, Options{"level": 1, "unknown": true}.Decode(&config))
+}
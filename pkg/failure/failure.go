@@ -0,0 +1,139 @@
+// Package failure classifies the failures of the data frame processing,
+// so the pipeline knows whether the failed processing should be retried.
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Kind is a class of the failure, that defines
+// how the failure should be handled.
+type Kind int
+
+const (
+	// KindUnknown is a failure that is not classified.
+	KindUnknown Kind = iota
+
+	// KindTransient is a temporary failure, e.g. the storage is not available.
+	// The operation could succeed if retried later.
+	KindTransient
+
+	// KindNotFound is a permanent failure, the requested object doesn't exist.
+	KindNotFound
+
+	// KindCorrupt is a permanent failure, the payload is malformed.
+	KindCorrupt
+
+	// KindRejected is a permanent failure, the converter or the storage
+	// has rejected the input.
+	KindRejected
+
+	// KindCanceled is a failure caused by the canceled context.
+	KindCanceled
+)
+
+// String returns the name of the failure kind.
+func (k Kind) String() string {
+	switch k {
+	case KindTransient:
+		return "transient"
+	case KindNotFound:
+		return "not_found"
+	case KindCorrupt:
+		return "corrupt"
+	case KindRejected:
+		return "rejected"
+	case KindCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is a classified failure.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// Error returns the text of the underlying error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Transient classifies the error as a transient failure.
+func Transient(err error) error {
+	return wrap(KindTransient, err)
+}
+
+// NotFound classifies the error as a permanent not found failure.
+func NotFound(err error) error {
+	return wrap(KindNotFound, err)
+}
+
+// Corrupt classifies the error as a corrupt payload failure.
+func Corrupt(err error) error {
+	return wrap(KindCorrupt, err)
+}
+
+// Rejected classifies the error as a failure of the converter
+// or the storage that has rejected the input.
+func Rejected(err error) error {
+	return wrap(KindRejected, err)
+}
+
+// Canceled classifies the error as a failure caused by the canceled context.
+func Canceled(err error) error {
+	return wrap(KindCanceled, err)
+}
+
+// Corruptf creates a new corrupt payload failure.
+func Corruptf(format string, args ...interface{}) error {
+	return Corrupt(fmt.Errorf(format, args...))
+}
+
+// KindOf returns the class of the failure.
+//
+// The errors caused by the canceled context or the exceeded deadline
+// are classified as canceled, unless they are classified explicitly.
+func KindOf(err error) Kind {
+	if err == nil {
+		return KindUnknown
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return KindCanceled
+	}
+
+	return KindUnknown
+}
+
+// IsPermanent reports whether the failure could not be fixed by a retry.
+func IsPermanent(err error) bool {
+	switch KindOf(err) {
+	case KindNotFound, KindCorrupt, KindRejected:
+		return true
+	default:
+		return false
+	}
+}
+
+// wrap classifies the error, nil errors are not wrapped.
+func wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
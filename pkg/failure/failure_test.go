@@ -0,0 +1,47 @@
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureKind(t *testing.T) {
+	cause := errors.New("failure")
+
+	for scenario, tc := range map[string]struct {
+		err       error
+		kind      Kind
+		permanent bool
+	}{
+		"unclassified":      {cause, KindUnknown, false},
+		"transient":         {Transient(cause), KindTransient, false},
+		"not found":         {NotFound(cause), KindNotFound, true},
+		"corrupt":           {Corrupt(cause), KindCorrupt, true},
+		"rejected":          {Rejected(cause), KindRejected, true},
+		"canceled":          {Canceled(cause), KindCanceled, false},
+		"context canceled":  {context.Canceled, KindCanceled, false},
+		"context deadline":  {fmt.Errorf("get: %w", context.DeadlineExceeded), KindCanceled, false},
+		"wrapped":           {fmt.Errorf("process: %w", Rejected(cause)), KindRejected, true},
+		"explicit over ctx": {Transient(context.DeadlineExceeded), KindTransient, false},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			require.Equal(t, tc.kind, KindOf(tc.err))
+			require.Equal(t, tc.permanent, IsPermanent(tc.err))
+		})
+	}
+}
+
+func TestFailureWrapping(t *testing.T) {
+	cause := errors.New("failure")
+
+	err := Corrupt(cause)
+	require.True(t, errors.Is(err, cause))
+	require.Equal(t, cause.Error(), err.Error())
+
+	require.Nil(t, Transient(nil))
+	require.Equal(t, KindUnknown, KindOf(nil))
+}